@@ -3,13 +3,12 @@ module github.com/vystepanenko/Chirpy
 go 1.23.2
 
 require (
+	github.com/coder/websocket v1.8.13
+	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 	golang.org/x/crypto v0.29.0
 )
 
-require (
-	github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
-	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
-)
+require github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+)
+
+// handlerBanUser bans a user. Their access tokens stop working right away,
+// all of their sessions end and they can not log in again until unbanned.
+func (cfg *apiConfig) handlerBanUser(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil || cfg.adminKey == "" || apiKey != cfg.adminKey {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	userId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+	_, err = cfg.db.GetUser(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: begin: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	_, err = q.BanUser(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: ban: "+err.Error())
+		return
+	}
+	_, err = q.DeleteUserSessions(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: delete sessions: "+err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: commit: "+err.Error())
+		return
+	}
+	cfg.tokenVersions.Invalidate(userId)
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerUnbanUser(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil || cfg.adminKey == "" || apiKey != cfg.adminKey {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	userId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+	_, err = cfg.db.GetUser(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+
+	_, err = cfg.db.UnbanUser(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: unban: "+err.Error())
+		return
+	}
+	cfg.tokenVersions.Invalidate(userId)
+
+	w.WriteHeader(204)
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/pagination"
+)
+
+const maxCollectionNameLength = 50
+
+type BookmarkCollection struct {
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name"`
+	BookmarkCount int64     `json:"bookmark_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func collectionFromDB(c database.BookmarkCollection) BookmarkCollection {
+	return BookmarkCollection{
+		ID:        c.ID,
+		Name:      c.Name,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+}
+
+type bookmarkRow struct {
+	chirp        database.Chirp
+	bookmarkedAt time.Time
+}
+
+func (cfg *apiConfig) handlerBookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	type requestBody struct {
+		CollectionID *uuid.UUID `json:"collection_id"`
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	// The body is optional; without a collection the bookmark is unfiled.
+	params := requestBody{}
+	if len(dat) > 0 {
+		err = json.Unmarshal(dat, &params)
+		if err != nil {
+			respondWithError(w, 400, "Something went wrong")
+			return
+		}
+	}
+
+	chirpIdParsed, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+	_, err = cfg.db.GetChirp(context.Background(), chirpIdParsed)
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	collectionId := uuid.NullUUID{}
+	if params.CollectionID != nil {
+		_, err = cfg.db.GetBookmarkCollection(context.Background(), database.GetBookmarkCollectionParams{
+			ID:     *params.CollectionID,
+			UserID: userId,
+		})
+		if err != nil {
+			respondWithError(w, 404, "Collection not found")
+			return
+		}
+		collectionId = uuid.NullUUID{UUID: *params.CollectionID, Valid: true}
+	}
+
+	err = cfg.db.BookmarkChirp(context.Background(), database.BookmarkChirpParams{
+		UserID:       userId,
+		ChirpID:      chirpIdParsed,
+		CollectionID: collectionId,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: bookmark: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerRemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	chirpIdParsed, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	_, err = cfg.db.RemoveBookmark(context.Background(), database.RemoveBookmarkParams{
+		UserID:  userId,
+		ChirpID: chirpIdParsed,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: remove bookmark: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetBookmarks(w http.ResponseWriter, r *http.Request) {
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	collectionId := uuid.NullUUID{}
+	if c := r.URL.Query().Get("collection_id"); c != "" {
+		id, err := uuid.Parse(c)
+		if err != nil {
+			respondWithError(w, 400, "collection_id must be a collection id")
+			return
+		}
+		collectionId = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorId := page.cursorParams()
+
+	// Most recently bookmarked first.
+	rows := []bookmarkRow{}
+	if !page.backward {
+		bookmarks, err := cfg.db.ListBookmarksDesc(context.Background(), database.ListBookmarksDescParams{
+			UserID:          userId,
+			CollectionID:    collectionId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+		if err != nil {
+			respondWithError(w, 400, "Error getting bookmarks")
+			return
+		}
+		for _, v := range bookmarks {
+			rows = append(rows, bookmarkRow{
+				chirp: database.Chirp{
+					ID:          v.ID,
+					UserID:      v.UserID,
+					Body:        v.Body,
+					CreatedAt:   v.CreatedAt,
+					UpdatedAt:   v.UpdatedAt,
+					ParentID:    v.ParentID,
+					RootID:      v.RootID,
+					RechirpOfID: v.RechirpOfID,
+					QuoteOfID:   v.QuoteOfID,
+					IsQuote:     v.IsQuote,
+				},
+				bookmarkedAt: v.BookmarkedAt,
+			})
+		}
+	} else {
+		bookmarks, err := cfg.db.ListBookmarksAsc(context.Background(), database.ListBookmarksAscParams{
+			UserID:          userId,
+			CollectionID:    collectionId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+		if err != nil {
+			respondWithError(w, 400, "Error getting bookmarks")
+			return
+		}
+		for _, v := range bookmarks {
+			rows = append(rows, bookmarkRow{
+				chirp: database.Chirp{
+					ID:          v.ID,
+					UserID:      v.UserID,
+					Body:        v.Body,
+					CreatedAt:   v.CreatedAt,
+					UpdatedAt:   v.UpdatedAt,
+					ParentID:    v.ParentID,
+					RootID:      v.RootID,
+					RechirpOfID: v.RechirpOfID,
+					QuoteOfID:   v.QuoteOfID,
+					IsQuote:     v.IsQuote,
+				},
+				bookmarkedAt: v.BookmarkedAt,
+			})
+		}
+	}
+
+	rows, prev, next := paginate(rows, page, func(b bookmarkRow) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: b.bookmarkedAt, ID: b.chirp.ID}
+	})
+
+	chirps := make([]database.Chirp, 0, len(rows))
+	for _, b := range rows {
+		chirps = append(chirps, b.chirp)
+	}
+	bookmarks, err := cfg.chirpsFromDB(uuid.NullUUID{UUID: userId, Valid: true}, chirps)
+	if err != nil {
+		respondWithError(w, 400, "Error getting bookmarks")
+		return
+	}
+
+	setPageHeaders(w, r, prev, next)
+	respondWithJSON(w, 200, bookmarks)
+}
+
+func parseCollectionName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxCollectionNameLength {
+		return "", errors.New("name must be 1 to 50 characters")
+	}
+	return name, nil
+}
+
+func (cfg *apiConfig) handlerCreateCollection(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	type requestBody struct {
+		Name string `json:"name"`
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	params := requestBody{}
+	err = json.Unmarshal(dat, &params)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	name, err := parseCollectionName(params.Name)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	c, err := cfg.db.CreateBookmarkCollection(context.Background(), database.CreateBookmarkCollectionParams{
+		UserID: userId,
+		Name:   name,
+	})
+	if isUniqueViolation(err, "bookmark_collections_user_id_name_idx") {
+		respondWithError(w, 409, "You already have a collection with this name")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: create collection: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, 201, collectionFromDB(c))
+}
+
+func (cfg *apiConfig) handlerGetCollections(w http.ResponseWriter, r *http.Request) {
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	rows, err := cfg.db.ListBookmarkCollections(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 400, "Error getting collections")
+		return
+	}
+
+	collections := make([]BookmarkCollection, 0, len(rows))
+	for _, v := range rows {
+		collections = append(collections, BookmarkCollection{
+			ID:            v.ID,
+			Name:          v.Name,
+			BookmarkCount: v.BookmarkCount,
+			CreatedAt:     v.CreatedAt,
+			UpdatedAt:     v.UpdatedAt,
+		})
+	}
+
+	respondWithJSON(w, 200, collections)
+}
+
+func (cfg *apiConfig) handlerRenameCollection(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	collectionId, err := uuid.Parse(r.PathValue("collectionId"))
+	if err != nil {
+		respondWithError(w, 404, "Collection not found")
+		return
+	}
+
+	type requestBody struct {
+		Name string `json:"name"`
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	params := requestBody{}
+	err = json.Unmarshal(dat, &params)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	name, err := parseCollectionName(params.Name)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	c, err := cfg.db.RenameBookmarkCollection(context.Background(), database.RenameBookmarkCollectionParams{
+		ID:     collectionId,
+		UserID: userId,
+		Name:   name,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Collection not found")
+		return
+	}
+	if isUniqueViolation(err, "bookmark_collections_user_id_name_idx") {
+		respondWithError(w, 409, "You already have a collection with this name")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: rename collection: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, collectionFromDB(c))
+}
+
+// handlerDeleteCollection deletes a collection. Its bookmarks are kept,
+// outside any collection.
+func (cfg *apiConfig) handlerDeleteCollection(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	collectionId, err := uuid.Parse(r.PathValue("collectionId"))
+	if err != nil {
+		respondWithError(w, 404, "Collection not found")
+		return
+	}
+
+	deleted, err := cfg.db.DeleteBookmarkCollection(context.Background(), database.DeleteBookmarkCollectionParams{
+		ID:     collectionId,
+		UserID: userId,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: delete collection: "+err.Error())
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "Collection not found")
+		return
+	}
+
+	w.WriteHeader(204)
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"io"
 	"net/http"
-	"sort"
 	"strings"
 	"time"
 
@@ -14,14 +13,59 @@ import (
 
 	"github.com/vystepanenko/Chirpy/internal/auth"
 	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/moderation"
+	"github.com/vystepanenko/Chirpy/internal/pagination"
 )
 
 type Chirpy struct {
-	ID        uuid.UUID `json:"id"`
-	UserId    uuid.UUID `json:"user_id"`
-	Body      string    `json:"body"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uuid.UUID     `json:"id"`
+	UserId    uuid.UUID     `json:"user_id"`
+	Body      string        `json:"body"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	InReplyTo *uuid.UUID    `json:"in_reply_to,omitempty"`
+	ThreadID  *uuid.UUID    `json:"thread_id,omitempty"`
+	LikeCount int64         `json:"like_count"`
+	LikedByMe bool          `json:"liked_by_me"`
+	RechirpOf *Chirpy       `json:"rechirp_of,omitempty"`
+	QuoteOf   *Chirpy       `json:"quote_of,omitempty"`
+	Media     []ChirpyMedia `json:"media,omitempty"`
+	Poll      *ChirpyPoll   `json:"poll,omitempty"`
+	// QuoteDeleted marks a quote whose original chirp has been deleted.
+	QuoteDeleted bool `json:"quote_deleted,omitempty"`
+	Edited       bool `json:"edited"`
+	// Snippet is set on search results, with matches wrapped in <mark>.
+	Snippet string `json:"snippet,omitempty"`
+	// Pinned is set on the pinned chirps of a profile.
+	Pinned bool `json:"pinned,omitempty"`
+	// Bookmarked is only ever reported for the viewer's own bookmarks.
+	Bookmarked bool `json:"bookmarked"`
+
+	rechirpOfId uuid.NullUUID
+	quoteOfId   uuid.NullUUID
+}
+
+func chirpFromDB(c database.Chirp) Chirpy {
+	return Chirpy{
+		ID:           c.ID,
+		UserId:       c.UserID,
+		Body:         c.Body,
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+		InReplyTo:    nullUUIDPtr(c.ParentID),
+		ThreadID:     nullUUIDPtr(c.RootID),
+		QuoteDeleted: c.IsQuote && !c.QuoteOfID.Valid,
+		Edited:       c.UpdatedAt.After(c.CreatedAt),
+		rechirpOfId:  c.RechirpOfID,
+		quoteOfId:    c.QuoteOfID,
+	}
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
 }
 
 func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request) {
@@ -32,14 +76,11 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, 401, "Unauthorized_bar")
 		return
 	}
-	userId, err := auth.ValidateJWT(bar, cfg.secretKey)
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
 	if err != nil {
 		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
 		return
 	}
-	type requestBody struct {
-		Body string `json:"body"`
-	}
 
 	dat, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -47,144 +88,178 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	params := requestBody{}
+	params := draftRequest{}
 	err = json.Unmarshal(dat, &params)
 	if err != nil {
 		respondWithError(w, 400, "Something went wrong")
 		return
 	}
 
-	cleaned, err := validateChirpBody(params.Body)
+	// A scheduled chirp is kept as a draft until it is due.
+	if params.ScheduledAt != nil {
+		d, err := cfg.saveDraft(userId, uuid.NullUUID{}, params.chirpRequest, params.ScheduledAt)
+		respondWithDraft(w, 202, d, err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
 	if err != nil {
-		respondWithError(w, 400, err.Error())
+		respondWithError(w, 400, "Something goes wrong: begin: "+err.Error())
 		return
 	}
-	chirpyParams := database.CreateChirpsParams{
-		Body:   cleaned,
-		UserID: userId,
+	defer tx.Rollback()
+
+	n, err := cfg.createChirp(cfg.db.WithTx(tx), userId, params.chirpRequest)
+	if err != nil {
+		respondWithChirpError(w, err)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: commit: "+err.Error())
+		return
 	}
+	cfg.publishChirp(n)
 
-	c, err := cfg.db.CreateChirps(context.Background(), chirpyParams)
+	chirp := chirpFromDB(n.chirp)
+	err = cfg.decorateChirps(uuid.NullUUID{UUID: userId, Valid: true}, []*Chirpy{&chirp})
 	if err != nil {
 		respondWithError(w, 400, err.Error())
 		return
 	}
 
-	respondWithJSON(w, 201, Chirpy{
-		ID:        c.ID,
-		UserId:    c.UserID,
-		Body:      c.Body,
-		CreatedAt: c.CreatedAt,
-		UpdatedAt: c.UpdatedAt,
-	},
-	)
+	respondWithJSON(w, 201, chirp)
 }
 
-func validateChirpBody(body string) (string, error) {
-	if len(body) > 140 {
-		return "", errors.New("Chirp is to long")
+func respondWithChirpError(w http.ResponseWriter, err error) {
+	var ce *chirpError
+	if errors.As(err, &ce) {
+		respondWithError(w, ce.status, ce.msg)
+		return
 	}
-
-	return cleanBody(body), nil
+	respondWithError(w, 400, "Something goes wrong: create chirp: "+err.Error())
 }
 
-func cleanBody(msg string) string {
-	badWords := map[string]struct{}{
-		"kerfuffle": {},
-		"sharbert":  {},
-		"fornax":    {},
+// referencedChirp resolves the chirp a rechirp or quote points at. Pointing
+// at a rechirp means pointing at the chirp it reposted.
+func referencedChirp(q *database.Queries, id string) (database.Chirp, error) {
+	chirpId, err := uuid.Parse(id)
+	if err != nil {
+		return database.Chirp{}, err
 	}
-	splitMsg := strings.Split(msg, " ")
 
-	cleanedWords := make([]string, 0)
-	for _, word := range splitMsg {
-		if _, ok := badWords[strings.ToLower(word)]; ok {
-			cleanedWords = append(cleanedWords, "****")
-		} else {
-			cleanedWords = append(cleanedWords, word)
-		}
+	c, err := q.GetChirp(context.Background(), chirpId)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if c.RechirpOfID.Valid {
+		return q.GetChirp(context.Background(), c.RechirpOfID.UUID)
 	}
 
-	return strings.Join(cleanedWords, " ")
+	return c, nil
+}
+
+func (cfg *apiConfig) validateChirpBody(body string) (moderation.Result, error) {
+	if len(body) > 140 {
+		return moderation.Result{}, errors.New("Chirp is to long")
+	}
+
+	return cfg.moderation.Run(body)
 }
 
 func (cfg *apiConfig) handlerGetAllChirps(w http.ResponseWriter, r *http.Request) {
-	auhorId := r.URL.Query().Get("author_id")
-	var chirps []database.Chirp
-	if auhorId != "" {
-		userId, err := uuid.Parse(auhorId)
-		if err != nil {
-			respondWithError(w, 400, "Error getting chirps")
-			return
-		}
-		chirps, err = cfg.db.GetAllChirpsByAuthor(context.Background(), userId)
-		if err != nil {
-			respondWithError(w, 400, "Error getting chirps")
-			return
-		}
-	} else {
-		var err error
-		chirps, err = cfg.db.GetAllChirps(context.Background())
+	authorId := uuid.NullUUID{}
+	if a := r.URL.Query().Get("author_id"); a != "" {
+		userId, err := uuid.Parse(a)
 		if err != nil {
 			respondWithError(w, 400, "Error getting chirps")
 			return
 		}
+		authorId = uuid.NullUUID{UUID: userId, Valid: true}
 	}
-	sort := r.URL.Query().Get("sort")
-	if sort == "" {
-		sort = "ASC"
+
+	sortOrder := strings.ToUpper(r.URL.Query().Get("sort"))
+	if sortOrder == "" {
+		sortOrder = "ASC"
+	}
+	if sortOrder != "ASC" && sortOrder != "DESC" {
+		respondWithError(w, 400, "sort must be asc or desc")
+		return
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
 	}
+	cursorCreatedAt, cursorId := page.cursorParams()
 
-	allChirps := make([]Chirpy, 0)
-	for _, v := range chirps {
-		allChirps = append(allChirps, Chirpy{
-			ID:        v.ID,
-			UserId:    v.UserID,
-			Body:      v.Body,
-			CreatedAt: v.CreatedAt,
-			UpdatedAt: v.UpdatedAt,
+	// A backward page walks the index in the opposite direction and is
+	// flipped back into the requested order by paginate.
+	var chirps []database.Chirp
+	if (sortOrder == "ASC") != page.backward {
+		chirps, err = cfg.db.ListChirpsAsc(context.Background(), database.ListChirpsAscParams{
+			AuthorID:        authorId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	} else {
+		chirps, err = cfg.db.ListChirpsDesc(context.Background(), database.ListChirpsDescParams{
+			AuthorID:        authorId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
 		})
 	}
+	if err != nil {
+		respondWithError(w, 400, "Error getting chirps")
+		return
+	}
 
-	allChirps = sortChirpsByCreatedAt(allChirps, strings.ToUpper(sort))
+	chirps, prev, next := paginate(chirps, page, chirpCursor)
+
+	allChirps, err := cfg.chirpsFromDB(cfg.optionalViewer(r), chirps)
+	if err != nil {
+		respondWithError(w, 400, "Error getting chirps")
+		return
+	}
+
+	setPageHeaders(w, r, prev, next)
 	respondWithJSON(w, 200, allChirps)
 }
 
-func sortChirpsByCreatedAt(allChirps []Chirpy, order string) []Chirpy {
-	sort.Slice(allChirps, func(i, j int) bool {
-		if order == "DESC" {
-			return allChirps[i].CreatedAt.After(allChirps[j].CreatedAt)
-		}
-		// Default to ASC
-		return allChirps[i].CreatedAt.Before(allChirps[j].CreatedAt)
-	})
-
-	return allChirps
+func chirpCursor(c database.Chirp) pagination.Cursor {
+	return pagination.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
 }
 
 func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
 	chirpId := r.PathValue("chirpId")
 	if "" == chirpId {
 		respondWithError(w, 422, "Please provide chirpId")
+		return
 	}
 
 	chirpIdParsed, err := uuid.Parse(chirpId)
 	if err != nil {
-		respondWithJSON(w, 404, "Chirp not found")
+		respondWithError(w, 404, "Chirp not found")
+		return
 	}
 
 	chirpDb, err := cfg.db.GetChirp(context.Background(), chirpIdParsed)
 	if err != nil {
-		respondWithJSON(w, 404, "Chirp not found")
+		respondWithError(w, 404, "Chirp not found")
+		return
 	}
 
-	respondWithJSON(w, 200, Chirpy{
-		ID:        chirpDb.ID,
-		UserId:    chirpDb.UserID,
-		Body:      chirpDb.Body,
-		CreatedAt: chirpDb.CreatedAt,
-		UpdatedAt: chirpDb.UpdatedAt,
-	})
+	chirp := chirpFromDB(chirpDb)
+	err = cfg.decorateChirps(cfg.optionalViewer(r), []*Chirpy{&chirp})
+	if err != nil {
+		respondWithError(w, 400, "Error getting chirp")
+		return
+	}
+
+	respondWithJSON(w, 200, chirp)
 }
 
 func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
@@ -195,7 +270,7 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, 401, "Unauthorized_bar")
 		return
 	}
-	userId, err := auth.ValidateJWT(bar, cfg.secretKey)
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
 	if err != nil {
 		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
 		return
@@ -215,6 +290,7 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 	chirpDb, err := cfg.db.GetChirp(context.Background(), chirpIdParsed)
 	if err != nil {
 		respondWithError(w, 404, "Chirp not found")
+		return
 	}
 
 	if chirpDb.UserID != userId {
@@ -222,14 +298,47 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	attachments, err := cfg.db.ListAttachmentsForChirps(context.Background(), []uuid.UUID{chirpDb.ID})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: media: "+err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: begin: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	// Replies and rechirps go with the chirp, and stream clients are told
+	// about each of them.
+	err = q.CreateChirpDeleteEvents(context.Background(), database.CreateChirpDeleteEventsParams{
+		ChirpID: chirpDb.ID,
+		Type:    chirpEventDeleted,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: events: "+err.Error())
+		return
+	}
+
 	dcParams := database.DeleteChirpParams{
 		ID:     chirpDb.ID,
 		UserID: userId,
 	}
-	err = cfg.db.DeleteChirp(context.Background(), dcParams)
+	err = q.DeleteChirp(context.Background(), dcParams)
 	if err != nil {
 		respondWithError(w, 400, "Something goes wrong: deleteChirp: "+err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: commit: "+err.Error())
+		return
 	}
+	go cfg.deleteMedia(attachments)
 
 	w.WriteHeader(204)
 }
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/pagination"
+)
+
+const (
+	maxScheduleAhead = 365 * 24 * time.Hour
+	// maxDraftAttempts is how often publishing a draft may fail for reasons
+	// other than the chirp itself before it is handed back to the user.
+	maxDraftAttempts = 8
+)
+
+type Draft struct {
+	ID uuid.UUID `json:"id"`
+	chirpRequest
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	// LastError says why a scheduled draft could not be published.
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type draftRequest struct {
+	chirpRequest
+	ScheduledAt *time.Time `json:"scheduled_at"`
+}
+
+func draftFromDB(d database.Draft) (Draft, error) {
+	draft := Draft{
+		ID:        d.ID,
+		LastError: d.LastError.String,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}
+	if d.ScheduledAt.Valid {
+		draft.ScheduledAt = &d.ScheduledAt.Time
+	}
+
+	return draft, json.Unmarshal(d.Payload, &draft.chirpRequest)
+}
+
+// saveDraft validates and stores a draft, creating it when draftId is not
+// set. The chirp is validated again when it is published.
+func (cfg *apiConfig) saveDraft(userId uuid.UUID, draftId uuid.NullUUID, params chirpRequest, scheduledAt *time.Time) (database.Draft, error) {
+	if params.RechirpOf != "" {
+		return database.Draft{}, &chirpError{400, "Rechirps can not be drafted"}
+	}
+	_, err := cfg.validateChirpBody(params.Body)
+	if err != nil {
+		return database.Draft{}, &chirpError{400, err.Error()}
+	}
+	if len(params.MediaIDs) > maxChirpMedia {
+		return database.Draft{}, &chirpError{400, "A chirp can have at most 4 images"}
+	}
+	if params.Poll != nil {
+		_, _, err = cfg.validatePoll(*params.Poll)
+		if err != nil {
+			return database.Draft{}, &chirpError{400, err.Error()}
+		}
+	}
+
+	scheduled := sql.NullTime{}
+	if scheduledAt != nil {
+		if !scheduledAt.After(time.Now()) || time.Until(*scheduledAt) > maxScheduleAhead {
+			return database.Draft{}, &chirpError{400, "scheduled_at must be in the next year"}
+		}
+		scheduled = sql.NullTime{Time: scheduledAt.UTC(), Valid: true}
+	}
+
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return database.Draft{}, err
+	}
+
+	if !draftId.Valid {
+		return cfg.db.CreateDraft(context.Background(), database.CreateDraftParams{
+			UserID:      userId,
+			Payload:     payload,
+			ScheduledAt: scheduled,
+		})
+	}
+
+	d, err := cfg.db.UpdateDraft(context.Background(), database.UpdateDraftParams{
+		ID:          draftId.UUID,
+		UserID:      userId,
+		Payload:     payload,
+		ScheduledAt: scheduled,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Draft{}, &chirpError{404, "Draft not found"}
+	}
+	return d, err
+}
+
+// respondWithDraft answers with a saved draft, or the reason it could not be
+// saved.
+func respondWithDraft(w http.ResponseWriter, code int, d database.Draft, err error) {
+	if err != nil {
+		respondWithChirpError(w, err)
+		return
+	}
+
+	draft, err := draftFromDB(d)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: draft: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, code, draft)
+}
+
+// publishDraft creates the chirp of a draft locked by q's transaction and
+// deletes the draft, so it can only be published once.
+func (cfg *apiConfig) publishDraft(q *database.Queries, d database.Draft) (newChirp, error) {
+	params := chirpRequest{}
+	err := json.Unmarshal(d.Payload, &params)
+	if err != nil {
+		return newChirp{}, err
+	}
+
+	n, err := cfg.createChirp(q, d.UserID, params)
+	if err != nil {
+		return newChirp{}, err
+	}
+
+	_, err = q.DeleteDraft(context.Background(), database.DeleteDraftParams{
+		ID:     d.ID,
+		UserID: d.UserID,
+	})
+	return n, err
+}
+
+// runScheduler publishes due drafts. Any number of server instances can run
+// it at the same time.
+func (cfg *apiConfig) runScheduler(interval time.Duration) {
+	for range time.Tick(interval) {
+		for cfg.publishDueDraft() {
+		}
+	}
+}
+
+// publishDueDraft publishes the next due draft and reports whether another
+// one should be tried right away.
+func (cfg *apiConfig) publishDueDraft() bool {
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		log.Printf("Error publishing scheduled chirps: %s\n", err)
+		return false
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	d, err := qtx.ClaimDueDraft(context.Background())
+	if errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		log.Printf("Error publishing scheduled chirps: %s\n", err)
+		return false
+	}
+
+	n, err := cfg.publishDraft(qtx, d)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		tx.Rollback()
+
+		// A chirp that is invalid now is handed back to the user right away;
+		// other errors are retried later, while the drafts due after this one
+		// go ahead.
+		msg := "The chirp could not be published"
+		var ce *chirpError
+		if errors.As(err, &ce) {
+			msg = ce.msg
+		} else {
+			log.Printf("Error publishing draft %s: %s\n", d.ID, err)
+			attempts, err := cfg.db.RetryDraft(context.Background(), d.ID)
+			if err != nil {
+				log.Printf("Error saving failure of draft %s: %s\n", d.ID, err)
+				return false
+			}
+			if attempts < maxDraftAttempts {
+				return true
+			}
+		}
+		err = cfg.db.FailDraft(context.Background(), database.FailDraftParams{
+			ID:        d.ID,
+			LastError: sql.NullString{String: msg, Valid: true},
+		})
+		if err != nil {
+			log.Printf("Error saving failure of draft %s: %s\n", d.ID, err)
+			return false
+		}
+		return true
+	}
+
+	cfg.publishChirp(n)
+	return true
+}
+
+func (cfg *apiConfig) handlerCreateDraft(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	params := draftRequest{}
+	err = json.Unmarshal(dat, &params)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	d, err := cfg.saveDraft(userId, uuid.NullUUID{}, params.chirpRequest, params.ScheduledAt)
+	respondWithDraft(w, 201, d, err)
+}
+
+func (cfg *apiConfig) handlerGetDrafts(w http.ResponseWriter, r *http.Request) {
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorId := page.cursorParams()
+
+	// Newest drafts first.
+	var rows []database.Draft
+	if !page.backward {
+		rows, err = cfg.db.ListDraftsDesc(context.Background(), database.ListDraftsDescParams{
+			UserID:          userId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	} else {
+		rows, err = cfg.db.ListDraftsAsc(context.Background(), database.ListDraftsAscParams{
+			UserID:          userId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, 400, "Error getting drafts")
+		return
+	}
+
+	rows, prev, next := paginate(rows, page, func(d database.Draft) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: d.CreatedAt, ID: d.ID}
+	})
+
+	drafts := make([]Draft, 0, len(rows))
+	for _, d := range rows {
+		draft, err := draftFromDB(d)
+		if err != nil {
+			respondWithError(w, 400, "Error getting drafts")
+			return
+		}
+		drafts = append(drafts, draft)
+	}
+
+	setPageHeaders(w, r, prev, next)
+	respondWithJSON(w, 200, drafts)
+}
+
+func (cfg *apiConfig) handlerGetDraft(w http.ResponseWriter, r *http.Request) {
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	draftId, err := uuid.Parse(r.PathValue("draftId"))
+	if err != nil {
+		respondWithError(w, 404, "Draft not found")
+		return
+	}
+
+	d, err := cfg.db.GetDraft(context.Background(), database.GetDraftParams{
+		ID:     draftId,
+		UserID: userId,
+	})
+	if err != nil {
+		respondWithError(w, 404, "Draft not found")
+		return
+	}
+
+	respondWithDraft(w, 200, d, nil)
+}
+
+func (cfg *apiConfig) handlerUpdateDraft(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	draftId, err := uuid.Parse(r.PathValue("draftId"))
+	if err != nil {
+		respondWithError(w, 404, "Draft not found")
+		return
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	params := draftRequest{}
+	err = json.Unmarshal(dat, &params)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	d, err := cfg.saveDraft(userId, uuid.NullUUID{UUID: draftId, Valid: true}, params.chirpRequest, params.ScheduledAt)
+	respondWithDraft(w, 200, d, err)
+}
+
+func (cfg *apiConfig) handlerDeleteDraft(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	draftId, err := uuid.Parse(r.PathValue("draftId"))
+	if err != nil {
+		respondWithError(w, 404, "Draft not found")
+		return
+	}
+
+	deleted, err := cfg.db.DeleteDraft(context.Background(), database.DeleteDraftParams{
+		ID:     draftId,
+		UserID: userId,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: deleteDraft: "+err.Error())
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "Draft not found")
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerPublishDraft(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	draftId, err := uuid.Parse(r.PathValue("draftId"))
+	if err != nil {
+		respondWithError(w, 404, "Draft not found")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: begin: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Waits for the scheduler if it is publishing the same draft, which is
+	// then gone.
+	d, err := qtx.GetDraftForUpdate(context.Background(), database.GetDraftForUpdateParams{
+		ID:     draftId,
+		UserID: userId,
+	})
+	if err != nil {
+		respondWithError(w, 404, "Draft not found")
+		return
+	}
+
+	n, err := cfg.publishDraft(qtx, d)
+	if err != nil {
+		respondWithChirpError(w, err)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: commit: "+err.Error())
+		return
+	}
+	cfg.publishChirp(n)
+
+	chirp := chirpFromDB(n.chirp)
+	err = cfg.decorateChirps(uuid.NullUUID{UUID: userId, Valid: true}, []*Chirpy{&chirp})
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 201, chirp)
+}
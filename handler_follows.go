@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/notifications"
+	"github.com/vystepanenko/Chirpy/internal/pagination"
+)
+
+type PublicUser struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle,omitempty"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type FollowUser struct {
+	PublicUser
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type followListResponse struct {
+	Count int64        `json:"count"`
+	Users []FollowUser `json:"users"`
+}
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	followee, err := cfg.userFromPath(r)
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+	if followee.ID == userId {
+		respondWithError(w, 400, "You can not follow yourself")
+		return
+	}
+
+	// The follow and its backfill commit together, so a failed backfill can
+	// be retried by following again.
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: begin: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	inserted, err := q.FollowUser(context.Background(), database.FollowUserParams{
+		FollowerID: userId,
+		FolloweeID: followee.ID,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: follow: "+err.Error())
+		return
+	}
+
+	if inserted > 0 {
+		err = q.BackfillTimeline(context.Background(), database.BackfillTimelineParams{
+			FollowerID: userId,
+			FolloweeID: followee.ID,
+		})
+		if err != nil {
+			respondWithError(w, 400, "Something goes wrong: backfill: "+err.Error())
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: commit: "+err.Error())
+		return
+	}
+
+	if inserted > 0 {
+		cfg.notifications.Emit(notifications.Event{
+			Type:        notifications.TypeFollow,
+			RecipientID: followee.ID,
+			ActorID:     uuid.NullUUID{UUID: userId, Valid: true},
+		})
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	followeeId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+	// The timeline cleanup below would take the user's own chirps away.
+	if followeeId == userId {
+		respondWithError(w, 400, "You can not unfollow yourself")
+		return
+	}
+
+	_, err = cfg.db.UnfollowUser(context.Background(), database.UnfollowUserParams{
+		FollowerID: userId,
+		FolloweeID: followeeId,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: unfollow: "+err.Error())
+		return
+	}
+
+	err = cfg.db.RemoveAuthorFromTimeline(context.Background(), database.RemoveAuthorFromTimelineParams{
+		UserID:   userId,
+		AuthorID: followeeId,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: timeline: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetFollowers(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.userFromPath(r)
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorId := page.cursorParams()
+
+	count, err := cfg.db.CountFollowers(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, 400, "Error getting followers")
+		return
+	}
+
+	// Newest followers first.
+	var rows []database.ListFollowersDescRow
+	if !page.backward {
+		rows, err = cfg.db.ListFollowersDesc(context.Background(), database.ListFollowersDescParams{
+			UserID:          user.ID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	} else {
+		var asc []database.ListFollowersAscRow
+		asc, err = cfg.db.ListFollowersAsc(context.Background(), database.ListFollowersAscParams{
+			UserID:          user.ID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+		for _, v := range asc {
+			rows = append(rows, database.ListFollowersDescRow(v))
+		}
+	}
+	if err != nil {
+		respondWithError(w, 400, "Error getting followers")
+		return
+	}
+
+	rows, prev, next := paginate(rows, page, func(v database.ListFollowersDescRow) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: v.FollowedAt, ID: v.ID}
+	})
+
+	users := make([]FollowUser, 0, len(rows))
+	for _, v := range rows {
+		users = append(users, FollowUser{
+			PublicUser: PublicUser{
+				ID:          v.ID,
+				Handle:      v.Handle.String,
+				DisplayName: v.DisplayName,
+				AvatarURL:   v.AvatarUrl,
+				CreatedAt:   v.CreatedAt,
+			},
+			FollowedAt: v.FollowedAt,
+		})
+	}
+
+	setPageHeaders(w, r, prev, next)
+	respondWithJSON(w, 200, followListResponse{Count: count, Users: users})
+}
+
+func (cfg *apiConfig) handlerGetFollowing(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.userFromPath(r)
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorId := page.cursorParams()
+
+	count, err := cfg.db.CountFollowing(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, 400, "Error getting following")
+		return
+	}
+
+	var rows []database.ListFollowingDescRow
+	if !page.backward {
+		rows, err = cfg.db.ListFollowingDesc(context.Background(), database.ListFollowingDescParams{
+			UserID:          user.ID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	} else {
+		var asc []database.ListFollowingAscRow
+		asc, err = cfg.db.ListFollowingAsc(context.Background(), database.ListFollowingAscParams{
+			UserID:          user.ID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+		for _, v := range asc {
+			rows = append(rows, database.ListFollowingDescRow(v))
+		}
+	}
+	if err != nil {
+		respondWithError(w, 400, "Error getting following")
+		return
+	}
+
+	rows, prev, next := paginate(rows, page, func(v database.ListFollowingDescRow) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: v.FollowedAt, ID: v.ID}
+	})
+
+	users := make([]FollowUser, 0, len(rows))
+	for _, v := range rows {
+		users = append(users, FollowUser{
+			PublicUser: PublicUser{
+				ID:          v.ID,
+				Handle:      v.Handle.String,
+				DisplayName: v.DisplayName,
+				AvatarURL:   v.AvatarUrl,
+				CreatedAt:   v.CreatedAt,
+			},
+			FollowedAt: v.FollowedAt,
+		})
+	}
+
+	setPageHeaders(w, r, prev, next)
+	respondWithJSON(w, 200, followListResponse{Count: count, Users: users})
+}
+
+func (cfg *apiConfig) userFromPath(r *http.Request) (database.User, error) {
+	userId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		return database.User{}, err
+	}
+
+	user, err := cfg.db.GetUser(context.Background(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errors.New("User not found")
+	}
+
+	return user, err
+}
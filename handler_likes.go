@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/notifications"
+)
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	chirpIdParsed, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+	chirp, err := cfg.db.GetChirp(context.Background(), chirpIdParsed)
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	inserted, err := cfg.db.LikeChirp(context.Background(), database.LikeChirpParams{
+		ChirpID: chirpIdParsed,
+		UserID:  userId,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: like: "+err.Error())
+		return
+	}
+	if inserted > 0 {
+		cfg.notify(notifications.TypeLike, chirp.UserID, userId, chirp.ID)
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	chirpIdParsed, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	_, err = cfg.db.UnlikeChirp(context.Background(), database.UnlikeChirpParams{
+		ChirpID: chirpIdParsed,
+		UserID:  userId,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: unlike: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
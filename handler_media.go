@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/media"
+)
+
+const (
+	maxChirpMedia  = 4
+	maxMediaBytes  = 5 << 20
+	maxUploadBytes = maxChirpMedia*maxMediaBytes + 64<<10
+	// Uploads not attached to a chirp within this time are deleted.
+	unattachedMediaAge = time.Hour
+)
+
+type ChirpyMedia struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+}
+
+func (cfg *apiConfig) mediaFromDB(a database.Attachment) ChirpyMedia {
+	return ChirpyMedia{
+		ID:           a.ID,
+		URL:          cfg.mediaStorage.URL(a.StorageKey),
+		ThumbnailURL: cfg.mediaStorage.URL(a.ThumbnailKey),
+		ContentType:  a.ContentType,
+		Width:        a.Width,
+		Height:       a.Height,
+	}
+}
+
+func mediaExtension(contentType string) string {
+	if contentType == "image/jpeg" {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+// handlerUploadMedia stores the images of a multipart upload. The returned
+// ids are passed as media_ids when creating a chirp.
+func (cfg *apiConfig) handlerUploadMedia(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		respondWithError(w, 400, "Expected a multipart/form-data body")
+		return
+	}
+
+	uploaded := []ChirpyMedia{}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, 413, "Upload is too large")
+			return
+		}
+		if err != nil {
+			respondWithError(w, 400, "Something went wrong")
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		if len(uploaded) == maxChirpMedia {
+			respondWithError(w, 400, "A chirp can have at most 4 images")
+			return
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, maxMediaBytes+1))
+		if errors.As(err, &maxBytesErr) || len(data) > maxMediaBytes {
+			respondWithError(w, 413, "Images can be at most 5 MB")
+			return
+		}
+		if err != nil {
+			respondWithError(w, 400, "Something went wrong")
+			return
+		}
+
+		processed, err := media.Process(data)
+		if errors.Is(err, media.ErrUnsupportedType) {
+			respondWithError(w, 415, err.Error())
+			return
+		}
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+
+		a, err := cfg.storeMedia(userId, processed)
+		if err != nil {
+			respondWithError(w, 500, "Something goes wrong: store media: "+err.Error())
+			return
+		}
+		uploaded = append(uploaded, cfg.mediaFromDB(a))
+	}
+
+	if len(uploaded) == 0 {
+		respondWithError(w, 400, "Please provide at least one file")
+		return
+	}
+
+	respondWithJSON(w, 201, uploaded)
+}
+
+// storeMedia saves an image and its thumbnail. Blobs saved before a failure
+// are left for sweepMedia.
+func (cfg *apiConfig) storeMedia(userId uuid.UUID, p media.Processed) (database.Attachment, error) {
+	id := uuid.New()
+	ext := mediaExtension(p.Image.ContentType)
+	key := userId.String() + "/" + id.String() + ext
+	thumbnailKey := userId.String() + "/" + id.String() + "_thumb" + ext
+
+	err := cfg.mediaStorage.Put(context.Background(), key, p.Image.Data, p.Image.ContentType)
+	if err != nil {
+		return database.Attachment{}, err
+	}
+	err = cfg.mediaStorage.Put(context.Background(), thumbnailKey, p.Thumbnail.Data, p.Thumbnail.ContentType)
+	if err != nil {
+		cfg.mediaStorage.Delete(context.Background(), key)
+		return database.Attachment{}, err
+	}
+
+	return cfg.db.CreateAttachment(context.Background(), database.CreateAttachmentParams{
+		ID:           id,
+		UserID:       userId,
+		StorageKey:   key,
+		ThumbnailKey: thumbnailKey,
+		ContentType:  p.Image.ContentType,
+		Width:        int32(p.Image.Width),
+		Height:       int32(p.Image.Height),
+	})
+}
+
+// deleteMedia removes attachments that are not used by a chirp. Each row is
+// claimed before its blobs are deleted, so an upload attached in the
+// meantime keeps its files. Blobs that fail to delete after the claim are
+// only logged.
+func (cfg *apiConfig) deleteMedia(attachments []database.Attachment) {
+	for _, a := range attachments {
+		claimed, err := cfg.db.ClaimUnattachedAttachment(context.Background(), a.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error deleting attachment %s: %s\n", a.ID, err)
+			continue
+		}
+
+		for _, key := range []string{claimed.StorageKey, claimed.ThumbnailKey} {
+			err = cfg.mediaStorage.Delete(context.Background(), key)
+			if err != nil {
+				log.Printf("Error deleting media %s of attachment %s: %s\n", key, a.ID, err)
+			}
+		}
+	}
+}
+
+// sweepMedia deletes uploads that were never attached to a chirp, and the
+// media of chirps removed without going through handlerDeleteChirp, such as
+// replies deleted along with their thread.
+func (cfg *apiConfig) sweepMedia(interval time.Duration) {
+	for range time.Tick(interval) {
+		attachments, err := cfg.db.ListUnattachedAttachments(context.Background(), database.ListUnattachedAttachmentsParams{
+			CreatedBefore: time.Now().UTC().Add(-unattachedMediaAge),
+			PageLimit:     500,
+		})
+		if err != nil {
+			log.Printf("Error listing unattached media: %s\n", err)
+			continue
+		}
+		cfg.deleteMedia(attachments)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/textparse"
+)
+
+// mentionChirp resolves the @handles in a chirp body to users, replacing any
+// mentions the chirp had before. Handles nobody owns are ignored.
+func mentionChirp(q *database.Queries, chirpId uuid.UUID, body string) ([]uuid.UUID, error) {
+	err := q.ClearChirpMentions(context.Background(), chirpId)
+	if err != nil {
+		return nil, err
+	}
+
+	handles := textparse.Mentions(body)
+	if len(handles) == 0 {
+		return nil, nil
+	}
+
+	return q.CreateMentions(context.Background(), database.CreateMentionsParams{
+		ChirpID: chirpId,
+		Handles: handles,
+	})
+}
+
+func (cfg *apiConfig) handlerGetMentions(w http.ResponseWriter, r *http.Request) {
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorId := page.cursorParams()
+
+	// Newest mentions first.
+	var chirps []database.Chirp
+	if !page.backward {
+		chirps, err = cfg.db.ListMentionsDesc(context.Background(), database.ListMentionsDescParams{
+			UserID:          userId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	} else {
+		chirps, err = cfg.db.ListMentionsAsc(context.Background(), database.ListMentionsAscParams{
+			UserID:          userId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, 400, "Error getting mentions")
+		return
+	}
+
+	chirps, prev, next := paginate(chirps, page, chirpCursor)
+
+	mentions, err := cfg.chirpsFromDB(uuid.NullUUID{UUID: userId, Valid: true}, chirps)
+	if err != nil {
+		respondWithError(w, 400, "Error getting mentions")
+		return
+	}
+
+	setPageHeaders(w, r, prev, next)
+	respondWithJSON(w, 200, mentions)
+}
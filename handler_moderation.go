@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/moderation"
+)
+
+// defaultModerationWords is used when no MODERATION_RULES_FILE is set.
+var defaultModerationWords = map[string]moderation.Action{
+	"kerfuffle": moderation.ActionMask,
+	"sharbert":  moderation.ActionMask,
+	"fornax":    moderation.ActionMask,
+}
+
+type ModerationWord struct {
+	Word   string `json:"word"`
+	Action string `json:"action"`
+}
+
+type ChirpFlag struct {
+	ID        uuid.UUID `json:"id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Reasons   []string  `json:"reasons"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// reloadModerationWords rebuilds the word list from the rules file with the
+// words managed through the admin API layered on top.
+func (cfg *apiConfig) reloadModerationWords() error {
+	dbWords, err := cfg.db.ListModerationWords(context.Background())
+	if err != nil {
+		return err
+	}
+
+	words := make(map[string]moderation.Action, len(cfg.moderationFileWords)+len(dbWords))
+	for word, action := range cfg.moderationFileWords {
+		words[word] = action
+	}
+	for _, v := range dbWords {
+		action, err := moderation.ParseAction(v.Action)
+		if err != nil {
+			return err
+		}
+		words[v.Word] = action
+	}
+
+	cfg.moderationWords.Replace(words)
+	return nil
+}
+
+// watchModerationWords keeps every instance in step with changes made
+// through the admin API on any other instance.
+func (cfg *apiConfig) watchModerationWords(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		err := cfg.reloadModerationWords()
+		if err != nil {
+			log.Printf("Error reloading moderation words: %s\n", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) recordChirpFlag(chirpId uuid.UUID, result moderation.Result) {
+	if result.Action != moderation.ActionFlag {
+		return
+	}
+
+	err := cfg.db.CreateChirpFlag(context.Background(), database.CreateChirpFlagParams{
+		ChirpID: chirpId,
+		Reasons: result.Reasons,
+	})
+	if err != nil {
+		log.Printf("Error flagging chirp %s: %s\n", chirpId, err)
+	}
+}
+
+func (cfg *apiConfig) handlerGetModerationWords(w http.ResponseWriter, r *http.Request) {
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil || cfg.adminKey == "" || apiKey != cfg.adminKey {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	words := make([]ModerationWord, 0)
+	for _, v := range cfg.moderationWords.Words() {
+		words = append(words, ModerationWord{Word: v.Word, Action: v.Action.String()})
+	}
+
+	respondWithJSON(w, 200, words)
+}
+
+func (cfg *apiConfig) handlerSetModerationWord(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil || cfg.adminKey == "" || apiKey != cfg.adminKey {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	word := moderation.Normalize(r.PathValue("word"))
+	if word == "" {
+		respondWithError(w, 422, "Please provide word")
+		return
+	}
+
+	type requestBody struct {
+		Action string `json:"action"`
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	params := requestBody{}
+	if len(dat) > 0 {
+		err = json.Unmarshal(dat, &params)
+		if err != nil {
+			respondWithError(w, 400, "Something went wrong")
+			return
+		}
+	}
+
+	action, err := moderation.ParseAction(params.Action)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	_, err = cfg.db.UpsertModerationWord(context.Background(), database.UpsertModerationWordParams{
+		Word:   word,
+		Action: action.String(),
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: upsertWord: "+err.Error())
+		return
+	}
+	cfg.moderationWords.Set(word, action)
+
+	respondWithJSON(w, 200, ModerationWord{Word: word, Action: action.String()})
+}
+
+func (cfg *apiConfig) handlerDeleteModerationWord(w http.ResponseWriter, r *http.Request) {
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil || cfg.adminKey == "" || apiKey != cfg.adminKey {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	word := moderation.Normalize(r.PathValue("word"))
+
+	// Words from the rules file come back on every reload, so they are
+	// switched off with an override instead of being deleted.
+	if _, ok := cfg.moderationFileWords[word]; ok {
+		_, err = cfg.db.UpsertModerationWord(context.Background(), database.UpsertModerationWordParams{
+			Word:   word,
+			Action: moderation.ActionAllow.String(),
+		})
+	} else {
+		err = cfg.db.DeleteModerationWord(context.Background(), word)
+	}
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: deleteWord: "+err.Error())
+		return
+	}
+	cfg.moderationWords.Remove(word)
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerGetChirpFlags(w http.ResponseWriter, r *http.Request) {
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil || cfg.adminKey == "" || apiKey != cfg.adminKey {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	limit := defaultPageLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			respondWithError(w, 400, "limit must be between 1 and "+strconv.Itoa(maxPageLimit))
+			return
+		}
+	}
+
+	flags, err := cfg.db.ListChirpFlags(context.Background(), int32(limit))
+	if err != nil {
+		respondWithError(w, 400, "Error getting flags")
+		return
+	}
+
+	result := make([]ChirpFlag, 0, len(flags))
+	for _, v := range flags {
+		result = append(result, ChirpFlag{
+			ID:        v.ID,
+			ChirpID:   v.ChirpID,
+			Reasons:   v.Reasons,
+			CreatedAt: v.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, 200, result)
+}
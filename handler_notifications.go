@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/notifications"
+	"github.com/vystepanenko/Chirpy/internal/pagination"
+)
+
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	Type      string     `json:"type"`
+	ActorID   *uuid.UUID `json:"actor_id,omitempty"`
+	ChirpID   *uuid.UUID `json:"chirp_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Read      bool       `json:"read"`
+}
+
+type notificationListResponse struct {
+	UnreadCount   int64          `json:"unread_count"`
+	Notifications []Notification `json:"notifications"`
+}
+
+func notificationFromDB(n database.Notification) Notification {
+	return Notification{
+		ID:        n.ID,
+		Type:      n.Type,
+		ActorID:   nullUUIDPtr(n.ActorID),
+		ChirpID:   nullUUIDPtr(n.ChirpID),
+		CreatedAt: n.CreatedAt,
+		Read:      n.ReadAt.Valid,
+	}
+}
+
+// notify queues a notification about a chirp for its author.
+func (cfg *apiConfig) notify(t notifications.Type, recipientId, actorId, chirpId uuid.UUID) {
+	cfg.notifications.Emit(notifications.Event{
+		Type:        t,
+		RecipientID: recipientId,
+		ActorID:     uuid.NullUUID{UUID: actorId, Valid: true},
+		ChirpID:     uuid.NullUUID{UUID: chirpId, Valid: true},
+	})
+}
+
+func (cfg *apiConfig) handlerGetNotifications(w http.ResponseWriter, r *http.Request) {
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorId := page.cursorParams()
+
+	// Newest notifications first.
+	var rows []database.Notification
+	if !page.backward {
+		rows, err = cfg.db.ListNotificationsDesc(context.Background(), database.ListNotificationsDescParams{
+			UserID:          userId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	} else {
+		rows, err = cfg.db.ListNotificationsAsc(context.Background(), database.ListNotificationsAscParams{
+			UserID:          userId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, 400, "Error getting notifications")
+		return
+	}
+
+	unread, err := cfg.db.CountUnreadNotifications(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 400, "Error getting notifications")
+		return
+	}
+
+	rows, prev, next := paginate(rows, page, func(n database.Notification) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: n.CreatedAt, ID: n.ID}
+	})
+
+	res := notificationListResponse{
+		UnreadCount:   unread,
+		Notifications: make([]Notification, 0, len(rows)),
+	}
+	for _, n := range rows {
+		res.Notifications = append(res.Notifications, notificationFromDB(n))
+	}
+
+	setPageHeaders(w, r, prev, next)
+	respondWithJSON(w, 200, res)
+}
+
+func (cfg *apiConfig) handlerReadNotification(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	notificationId, err := uuid.Parse(r.PathValue("notificationId"))
+	if err != nil {
+		respondWithError(w, 404, "Notification not found")
+		return
+	}
+
+	// Marking an already read notification is a no-op, so zero rows is not
+	// an error here.
+	_, err = cfg.db.MarkNotificationRead(context.Background(), database.MarkNotificationReadParams{
+		ID:     notificationId,
+		UserID: userId,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: read: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerReadAllNotifications(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	_, err = cfg.db.MarkAllNotificationsRead(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: read all: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
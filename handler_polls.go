@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 25
+	defaultPollDuration = 24 * time.Hour
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 7 * 24 * time.Hour
+)
+
+type ChirpyPoll struct {
+	ClosesAt time.Time          `json:"closes_at"`
+	Closed   bool               `json:"closed"`
+	Options  []ChirpyPollOption `json:"options"`
+	// Results are only shown once the viewer has voted or the poll has
+	// closed, so the counts do not sway the vote.
+	TotalVotes  *int64     `json:"total_votes,omitempty"`
+	VotedOption *uuid.UUID `json:"voted_option,omitempty"`
+}
+
+type ChirpyPollOption struct {
+	ID    uuid.UUID `json:"id"`
+	Text  string    `json:"text"`
+	Votes *int64    `json:"votes,omitempty"`
+}
+
+type pollRequest struct {
+	Options  []string `json:"options"`
+	Duration string   `json:"duration"`
+}
+
+// validatePoll checks the poll of a new chirp, returning its moderated
+// options and how long it stays open.
+func (cfg *apiConfig) validatePoll(p pollRequest) ([]string, time.Duration, error) {
+	if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+		return nil, 0, errors.New("A poll needs 2 to 4 options")
+	}
+
+	options := make([]string, 0, len(p.Options))
+	seen := map[string]bool{}
+	for _, o := range p.Options {
+		o = strings.TrimSpace(o)
+		if o == "" || utf8.RuneCountInString(o) > maxPollOptionLength {
+			return nil, 0, errors.New("Poll options must be 1 to 25 characters")
+		}
+		if seen[strings.ToLower(o)] {
+			return nil, 0, errors.New("Poll options must be different")
+		}
+		seen[strings.ToLower(o)] = true
+
+		moderated, err := cfg.moderation.Run(o)
+		if err != nil {
+			return nil, 0, err
+		}
+		options = append(options, moderated.Body)
+	}
+
+	duration := defaultPollDuration
+	if p.Duration != "" {
+		parsed, err := time.ParseDuration(p.Duration)
+		if err != nil || parsed < minPollDuration || parsed > maxPollDuration {
+			return nil, 0, errors.New("duration must be between " + minPollDuration.String() + " and " + maxPollDuration.String())
+		}
+		duration = parsed
+	}
+
+	return options, duration, nil
+}
+
+func createPoll(q *database.Queries, chirpId uuid.UUID, options []string, duration time.Duration) error {
+	err := q.CreatePoll(context.Background(), database.CreatePollParams{
+		ChirpID:         chirpId,
+		DurationSeconds: duration.Seconds(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.CreatePollOptions(context.Background(), database.CreatePollOptionsParams{
+		ChirpID: chirpId,
+		Texts:   options,
+	})
+}
+
+// decoratePolls fills in the polls of a page of chirps.
+func (cfg *apiConfig) decoratePolls(viewer uuid.NullUUID, chirps []*Chirpy, ids []uuid.UUID) error {
+	polls, err := cfg.db.ListPollsForChirps(context.Background(), ids)
+	if err != nil || len(polls) == 0 {
+		return err
+	}
+
+	pollIds := make([]uuid.UUID, 0, len(polls))
+	byChirp := make(map[uuid.UUID]*ChirpyPoll, len(polls))
+	for _, p := range polls {
+		pollIds = append(pollIds, p.ChirpID)
+		byChirp[p.ChirpID] = &ChirpyPoll{ClosesAt: p.ClosesAt, Closed: p.Closed, Options: []ChirpyPollOption{}}
+	}
+
+	votedFor := map[uuid.UUID]uuid.UUID{}
+	if viewer.Valid {
+		votes, err := cfg.db.ListPollVotesOfUser(context.Background(), database.ListPollVotesOfUserParams{
+			UserID:   viewer.UUID,
+			ChirpIds: pollIds,
+		})
+		if err != nil {
+			return err
+		}
+		for _, v := range votes {
+			votedFor[v.ChirpID] = v.OptionID
+		}
+	}
+
+	options, err := cfg.db.ListPollOptionsForChirps(context.Background(), pollIds)
+	if err != nil {
+		return err
+	}
+	totals := map[uuid.UUID]int64{}
+	for _, o := range options {
+		p := byChirp[o.ChirpID]
+		option := ChirpyPollOption{ID: o.ID, Text: o.Text}
+		if _, voted := votedFor[o.ChirpID]; voted || p.Closed {
+			option.Votes = &o.Votes
+			totals[o.ChirpID] += o.Votes
+		}
+		p.Options = append(p.Options, option)
+	}
+
+	for chirpId, p := range byChirp {
+		if optionId, voted := votedFor[chirpId]; voted {
+			p.VotedOption = &optionId
+		}
+		if total, ok := totals[chirpId]; ok {
+			p.TotalVotes = &total
+		}
+	}
+
+	for _, c := range chirps {
+		c.Poll = byChirp[c.ID]
+	}
+
+	return nil
+}
+
+func (cfg *apiConfig) handlerVotePoll(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	type requestBody struct {
+		OptionID uuid.UUID `json:"option_id"`
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	params := requestBody{}
+	err = json.Unmarshal(dat, &params)
+	if err != nil {
+		respondWithError(w, 400, "option_id must be a poll option id")
+		return
+	}
+
+	chirpIdParsed, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+	chirpDb, err := cfg.db.GetChirp(context.Background(), chirpIdParsed)
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	viewer := uuid.NullUUID{UUID: userId, Valid: true}
+	chirp := chirpFromDB(chirpDb)
+	err = cfg.decoratePolls(viewer, []*Chirpy{&chirp}, []uuid.UUID{chirp.ID})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: poll: "+err.Error())
+		return
+	}
+	if chirp.Poll == nil {
+		respondWithError(w, 404, "Poll not found")
+		return
+	}
+	if chirp.Poll.Closed {
+		respondWithError(w, 409, "Poll is closed")
+		return
+	}
+	if chirp.Poll.VotedOption != nil {
+		respondWithError(w, 409, "You already voted")
+		return
+	}
+	found := false
+	for _, o := range chirp.Poll.Options {
+		found = found || o.ID == params.OptionID
+	}
+	if !found {
+		respondWithError(w, 400, "option_id must be an option of this poll")
+		return
+	}
+
+	// The checks above can race with another vote or the poll closing, so
+	// the insert checks again.
+	inserted, err := cfg.db.VotePoll(context.Background(), database.VotePollParams{
+		UserID:   userId,
+		OptionID: params.OptionID,
+		ChirpID:  chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: vote: "+err.Error())
+		return
+	}
+	if inserted == 0 {
+		respondWithError(w, 409, "You already voted or the poll is closed")
+		return
+	}
+
+	err = cfg.decoratePolls(viewer, []*Chirpy{&chirp}, []uuid.UUID{chirp.ID})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: poll: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, 201, chirp.Poll)
+}
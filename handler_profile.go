@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/profile"
+	"github.com/vystepanenko/Chirpy/internal/textparse"
+)
+
+// Chirpy Red members can pin more chirps to their profile.
+const (
+	maxPinnedChirps    = 1
+	maxPinnedChirpsRed = 5
+	profileChirpsLimit = 20
+)
+
+type Profile struct {
+	PublicUser
+	Bio            string `json:"bio"`
+	Location       string `json:"location"`
+	Website        string `json:"website"`
+	IsChirpyRed    bool   `json:"is_chirpy_red"`
+	FollowerCount  int64  `json:"follower_count"`
+	FollowingCount int64  `json:"following_count"`
+	ChirpCount     int64  `json:"chirp_count"`
+	// Chirps holds the pinned chirps, followed by the most recent ones.
+	Chirps []Chirpy `json:"chirps"`
+}
+
+func pinnedChirpsLimit(u database.User) int64 {
+	if u.IsChirpyRed {
+		return maxPinnedChirpsRed
+	}
+	return maxPinnedChirps
+}
+
+func (cfg *apiConfig) handlerPinChirp(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	chirpIdParsed, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+	chirp, err := cfg.db.GetChirp(context.Background(), chirpIdParsed)
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+	if chirp.UserID != userId {
+		respondWithError(w, 403, "You can only pin your own chirps")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: begin: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	// Locking the user row keeps concurrent pins from going over the limit.
+	user, err := q.GetUserForUpdate(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: user: "+err.Error())
+		return
+	}
+
+	inserted, err := q.PinChirp(context.Background(), database.PinChirpParams{
+		UserID:  userId,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: pin: "+err.Error())
+		return
+	}
+
+	if inserted > 0 {
+		count, err := q.CountPinnedChirps(context.Background(), userId)
+		if err != nil {
+			respondWithError(w, 400, "Something goes wrong: pin: "+err.Error())
+			return
+		}
+		limit := pinnedChirpsLimit(user)
+		if count > limit {
+			respondWithError(w, 409, "You can pin at most "+strconv.FormatInt(limit, 10)+" chirps")
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: commit: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) handlerUnpinChirp(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	chirpIdParsed, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	_, err = cfg.db.UnpinChirp(context.Background(), database.UnpinChirpParams{
+		UserID:  userId,
+		ChirpID: chirpIdParsed,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: unpin: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func publicUserFromDB(u database.User) PublicUser {
+	return PublicUser{
+		ID:          u.ID,
+		Handle:      u.Handle.String,
+		DisplayName: u.DisplayName,
+		AvatarURL:   u.AvatarUrl,
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+func (cfg *apiConfig) handlerGetProfile(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.userFromPath(r)
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+
+	cfg.respondWithProfile(w, r, user)
+}
+
+func (cfg *apiConfig) handlerGetUserByHandle(w http.ResponseWriter, r *http.Request) {
+	handle := strings.TrimPrefix(r.PathValue("handle"), "@")
+	if textparse.ValidateHandle(handle) != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+
+	user, err := cfg.db.GetUserByHandle(context.Background(), handle)
+	if err != nil {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+
+	cfg.respondWithProfile(w, r, user)
+}
+
+func (cfg *apiConfig) respondWithProfile(w http.ResponseWriter, r *http.Request, user database.User) {
+	followers, err := cfg.db.CountFollowers(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, 400, "Error getting profile")
+		return
+	}
+	following, err := cfg.db.CountFollowing(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, 400, "Error getting profile")
+		return
+	}
+	chirpCount, err := cfg.db.CountChirpsByAuthor(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, 400, "Error getting profile")
+		return
+	}
+
+	pinned, err := cfg.db.ListPinnedChirps(context.Background(), user.ID)
+	if err != nil {
+		respondWithError(w, 400, "Error getting profile")
+		return
+	}
+	recent, err := cfg.db.ListChirpsDesc(context.Background(), database.ListChirpsDescParams{
+		AuthorID:  uuid.NullUUID{UUID: user.ID, Valid: true},
+		PageLimit: profileChirpsLimit,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Error getting profile")
+		return
+	}
+
+	// Pinned chirps are listed once, at the top.
+	isPinned := make(map[uuid.UUID]struct{}, len(pinned))
+	for _, c := range pinned {
+		isPinned[c.ID] = struct{}{}
+	}
+	chirpsDb := pinned
+	for _, c := range recent {
+		if _, ok := isPinned[c.ID]; !ok {
+			chirpsDb = append(chirpsDb, c)
+		}
+	}
+
+	chirps, err := cfg.chirpsFromDB(cfg.optionalViewer(r), chirpsDb)
+	if err != nil {
+		respondWithError(w, 400, "Error getting profile")
+		return
+	}
+	for i := range pinned {
+		chirps[i].Pinned = true
+	}
+
+	respondWithJSON(w, 200, Profile{
+		PublicUser:     publicUserFromDB(user),
+		Bio:            user.Bio,
+		Location:       user.Location,
+		Website:        user.Website,
+		IsChirpyRed:    user.IsChirpyRed,
+		FollowerCount:  followers,
+		FollowingCount: following,
+		ChirpCount:     chirpCount,
+		Chirps:         chirps,
+	})
+}
+
+// handlerUpdateProfile applies a partial update: fields left out of the body
+// keep their value and an empty string clears a field.
+func (cfg *apiConfig) handlerUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	type requestBody struct {
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		AvatarURL   *string `json:"avatar_url"`
+		Location    *string `json:"location"`
+		Website     *string `json:"website"`
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: body")
+		return
+	}
+
+	params := requestBody{}
+	err = json.Unmarshal(dat, &params)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: unmarshal: "+err.Error())
+		return
+	}
+
+	handle, err := parseHandleUpdate(params.Handle)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	upParams := database.UpdateUserProfileParams{ID: userId, Handle: handle}
+
+	fields := []struct {
+		value    *string
+		validate func(string) (string, error)
+		dest     *sql.NullString
+	}{
+		{params.DisplayName, profile.DisplayName, &upParams.DisplayName},
+		{params.Bio, profile.Bio, &upParams.Bio},
+		{params.AvatarURL, profile.AvatarURL, &upParams.AvatarUrl},
+		{params.Location, profile.Location, &upParams.Location},
+		{params.Website, profile.Website, &upParams.Website},
+	}
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+		v, err := f.validate(*f.value)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		*f.dest = sql.NullString{String: v, Valid: true}
+	}
+
+	u, err := cfg.db.UpdateUserProfile(context.Background(), upParams)
+	if respondIfHandleTaken(w, err) {
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: updateProfile: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, userFromDB(u))
+}
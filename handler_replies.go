@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/database"
+)
+
+type ChirpyThreadNode struct {
+	Chirpy
+	Depth      int                 `json:"depth"`
+	ReplyCount int                 `json:"reply_count"`
+	Replies    []*ChirpyThreadNode `json:"replies"`
+}
+
+func (cfg *apiConfig) handlerGetChirpReplies(w http.ResponseWriter, r *http.Request) {
+	chirpIdParsed, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	_, err = cfg.db.GetChirp(context.Background(), chirpIdParsed)
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorId := page.cursorParams()
+	parentId := uuid.NullUUID{UUID: chirpIdParsed, Valid: true}
+
+	// Replies read oldest first, like a conversation.
+	var replies []database.Chirp
+	if !page.backward {
+		replies, err = cfg.db.ListChirpRepliesAsc(context.Background(), database.ListChirpRepliesAscParams{
+			ParentID:        parentId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	} else {
+		replies, err = cfg.db.ListChirpRepliesDesc(context.Background(), database.ListChirpRepliesDescParams{
+			ParentID:        parentId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, 400, "Error getting replies")
+		return
+	}
+
+	replies, prev, next := paginate(replies, page, chirpCursor)
+
+	allReplies, err := cfg.chirpsFromDB(cfg.optionalViewer(r), replies)
+	if err != nil {
+		respondWithError(w, 400, "Error getting replies")
+		return
+	}
+
+	setPageHeaders(w, r, prev, next)
+	respondWithJSON(w, 200, allReplies)
+}
+
+func (cfg *apiConfig) handlerGetChirpThread(w http.ResponseWriter, r *http.Request) {
+	chirpIdParsed, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	chirpDb, err := cfg.db.GetChirp(context.Background(), chirpIdParsed)
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	rootId := chirpDb.ID
+	if chirpDb.RootID.Valid {
+		rootId = chirpDb.RootID.UUID
+	}
+
+	rows, err := cfg.db.GetThread(context.Background(), rootId)
+	if err != nil || len(rows) == 0 {
+		respondWithError(w, 400, "Error getting thread")
+		return
+	}
+
+	root, nodes := buildThread(rows)
+
+	chirps := make([]*Chirpy, 0, len(nodes))
+	for _, node := range nodes {
+		chirps = append(chirps, &node.Chirpy)
+	}
+	err = cfg.decorateChirps(cfg.optionalViewer(r), chirps)
+	if err != nil {
+		respondWithError(w, 400, "Error getting thread")
+		return
+	}
+
+	respondWithJSON(w, 200, root)
+}
+
+// buildThread relies on GetThread returning rows ordered by depth, so every
+// parent is seen before its replies.
+func buildThread(rows []database.GetThreadRow) (*ChirpyThreadNode, map[uuid.UUID]*ChirpyThreadNode) {
+	nodes := make(map[uuid.UUID]*ChirpyThreadNode, len(rows))
+	var root *ChirpyThreadNode
+
+	for _, row := range rows {
+		node := &ChirpyThreadNode{
+			Chirpy: chirpFromDB(database.Chirp{
+				ID:          row.ID,
+				UserID:      row.UserID,
+				Body:        row.Body,
+				CreatedAt:   row.CreatedAt,
+				UpdatedAt:   row.UpdatedAt,
+				ParentID:    row.ParentID,
+				RootID:      row.RootID,
+				RechirpOfID: row.RechirpOfID,
+				QuoteOfID:   row.QuoteOfID,
+				IsQuote:     row.IsQuote,
+			}),
+			Depth:      int(row.Depth),
+			ReplyCount: int(row.ReplyCount),
+			Replies:    make([]*ChirpyThreadNode, 0),
+		}
+		nodes[row.ID] = node
+
+		if root == nil {
+			root = node
+			continue
+		}
+		if parent, ok := nodes[row.ParentID.UUID]; ok {
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+
+	return root, nodes
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+)
+
+// chirpEditWindow limits how long after posting a chirp can be edited.
+// Chirpy Red members can edit at any time.
+const chirpEditWindow = time.Hour
+
+type ChirpyRevision struct {
+	ID         uuid.UUID `json:"id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+func (cfg *apiConfig) handlerUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	chirpIdParsed, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	type requestBody struct {
+		Body string `json:"body"`
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	params := requestBody{}
+	err = json.Unmarshal(dat, &params)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	moderated, err := cfg.validateChirpBody(params.Body)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	user, err := cfg.db.GetUser(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: begin: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Lock the row so concurrent edits can not lose a revision.
+	chirpDb, err := qtx.GetChirpForUpdate(context.Background(), chirpIdParsed)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: getChirp: "+err.Error())
+		return
+	}
+
+	if chirpDb.UserID != userId {
+		respondWithError(w, 403, "Forbiden")
+		return
+	}
+	if chirpDb.RechirpOfID.Valid {
+		respondWithError(w, 400, "Rechirps can not be edited")
+		return
+	}
+	if chirpDb.IsQuote && moderated.Body == "" {
+		respondWithError(w, 400, "A quote needs a body")
+		return
+	}
+	if !user.IsChirpyRed && time.Since(chirpDb.CreatedAt) > chirpEditWindow {
+		respondWithError(w, 400, "Chirp can no longer be edited")
+		return
+	}
+
+	if moderated.Body != chirpDb.Body {
+		_, err = qtx.CreateChirpRevision(context.Background(), database.CreateChirpRevisionParams{
+			ChirpID:   chirpDb.ID,
+			Body:      chirpDb.Body,
+			CreatedAt: chirpDb.UpdatedAt,
+		})
+		if err != nil {
+			respondWithError(w, 400, "Something goes wrong: revision: "+err.Error())
+			return
+		}
+
+		chirpDb, err = qtx.UpdateChirpBody(context.Background(), database.UpdateChirpBodyParams{
+			Body: moderated.Body,
+			ID:   chirpDb.ID,
+		})
+		if err != nil {
+			respondWithError(w, 400, "Something goes wrong: updateChirp: "+err.Error())
+			return
+		}
+
+		err = tagChirp(qtx, chirpDb.ID, chirpDb.Body)
+		if err != nil {
+			respondWithError(w, 400, "Something goes wrong: tags: "+err.Error())
+			return
+		}
+
+		_, err = mentionChirp(qtx, chirpDb.ID, chirpDb.Body)
+		if err != nil {
+			respondWithError(w, 400, "Something goes wrong: mentions: "+err.Error())
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: commit: "+err.Error())
+		return
+	}
+	cfg.recordChirpFlag(chirpDb.ID, moderated)
+
+	chirp := chirpFromDB(chirpDb)
+	err = cfg.decorateChirps(uuid.NullUUID{UUID: userId, Valid: true}, []*Chirpy{&chirp})
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, chirp)
+}
+
+func (cfg *apiConfig) handlerGetChirpHistory(w http.ResponseWriter, r *http.Request) {
+	chirpIdParsed, err := uuid.Parse(r.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	chirpDb, err := cfg.db.GetChirp(context.Background(), chirpIdParsed)
+	if err != nil {
+		respondWithError(w, 404, "Chirp not found")
+		return
+	}
+
+	revisions, err := cfg.db.ListChirpRevisions(context.Background(), chirpDb.ID)
+	if err != nil {
+		respondWithError(w, 400, "Error getting history")
+		return
+	}
+
+	type responseBody struct {
+		Chirp     Chirpy           `json:"chirp"`
+		Revisions []ChirpyRevision `json:"revisions"`
+	}
+
+	chirp := chirpFromDB(chirpDb)
+	err = cfg.decorateChirps(cfg.optionalViewer(r), []*Chirpy{&chirp})
+	if err != nil {
+		respondWithError(w, 400, "Error getting history")
+		return
+	}
+
+	history := make([]ChirpyRevision, 0, len(revisions))
+	for _, v := range revisions {
+		history = append(history, ChirpyRevision{
+			ID:         v.ID,
+			Body:       v.Body,
+			CreatedAt:  v.CreatedAt,
+			ReplacedAt: v.ReplacedAt,
+		})
+	}
+
+	respondWithJSON(w, 200, responseBody{
+		Chirp:     chirp,
+		Revisions: history,
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/database"
+)
+
+const maxSearchQueryLength = 200
+
+// snippetHighlighter turns the private-use markers SearchChirps wraps around
+// matches into <mark> tags once the rest of the snippet has been escaped.
+var snippetHighlighter = strings.NewReplacer("\ue000", "<mark>", "\ue001", "</mark>")
+
+func (cfg *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		respondWithError(w, 400, "Please provide q")
+		return
+	}
+	if len(q) > maxSearchQueryLength {
+		respondWithError(w, 400, "Search query is to long")
+		return
+	}
+
+	authorId := uuid.NullUUID{}
+	if a := r.URL.Query().Get("author_id"); a != "" {
+		userId, err := uuid.Parse(a)
+		if err != nil {
+			respondWithError(w, 400, "Error searching chirps")
+			return
+		}
+		authorId = uuid.NullUUID{UUID: userId, Valid: true}
+	}
+
+	page, err := parseOffsetPageRequest(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	rows, err := cfg.db.SearchChirps(context.Background(), database.SearchChirpsParams{
+		Query:      q,
+		AuthorID:   authorId,
+		PageLimit:  page.queryLimit(),
+		PageOffset: page.offset,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Error searching chirps")
+		return
+	}
+
+	rows, prev, next := paginateOffset(rows, page)
+
+	chirps := make([]database.Chirp, 0, len(rows))
+	for _, v := range rows {
+		chirps = append(chirps, database.Chirp{
+			ID:          v.ID,
+			UserID:      v.UserID,
+			Body:        v.Body,
+			CreatedAt:   v.CreatedAt,
+			UpdatedAt:   v.UpdatedAt,
+			ParentID:    v.ParentID,
+			RootID:      v.RootID,
+			RechirpOfID: v.RechirpOfID,
+			QuoteOfID:   v.QuoteOfID,
+			IsQuote:     v.IsQuote,
+		})
+	}
+
+	results, err := cfg.chirpsFromDB(cfg.optionalViewer(r), chirps)
+	if err != nil {
+		respondWithError(w, 400, "Error searching chirps")
+		return
+	}
+	for i, v := range rows {
+		results[i].Snippet = snippetHighlighter.Replace(html.EscapeString(v.Snippet))
+	}
+
+	setPageHeaders(w, r, prev, next)
+	respondWithJSON(w, 200, results)
+}
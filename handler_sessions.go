@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+)
+
+const (
+	maxDeviceLabelLength = 50
+	maxUserAgentLength   = 256
+)
+
+type Session struct {
+	ID          uuid.UUID `json:"id"`
+	DeviceLabel string    `json:"device_label"`
+	UserAgent   string    `json:"user_agent"`
+	IP          string    `json:"ip"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastUsedAt  time.Time `json:"last_used_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func sessionFromDB(s database.Session) Session {
+	return Session{
+		ID:          s.ID,
+		DeviceLabel: s.DeviceLabel,
+		UserAgent:   s.UserAgent,
+		IP:          s.Ip,
+		CreatedAt:   s.CreatedAt,
+		LastUsedAt:  s.LastUsedAt,
+		ExpiresAt:   s.ExpiresAt,
+	}
+}
+
+// startSession records a login and returns the id of the new session and
+// its first refresh token.
+func (cfg *apiConfig) startSession(r *http.Request, userId uuid.UUID, label string) (uuid.UUID, string, error) {
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return uuid.UUID{}, "", err
+	}
+	defer tx.Rollback()
+
+	sessionId, refreshToken, err := createSession(cfg.db.WithTx(tx), r, userId, label)
+	if err != nil {
+		return uuid.UUID{}, "", err
+	}
+
+	return sessionId, refreshToken, tx.Commit()
+}
+
+// createSession is startSession within a transaction of the caller.
+func createSession(q *database.Queries, r *http.Request, userId uuid.UUID, label string) (uuid.UUID, string, error) {
+	s, err := q.CreateSession(context.Background(), database.CreateSessionParams{
+		UserID:      userId,
+		UserAgent:   truncateUserAgent(r.UserAgent()),
+		Ip:          clientIP(r),
+		DeviceLabel: label,
+	})
+	if err != nil {
+		return uuid.UUID{}, "", err
+	}
+
+	refreshToken, err := issueRefreshToken(q, userId, s.ID)
+	if err != nil {
+		return uuid.UUID{}, "", err
+	}
+	return s.ID, refreshToken, nil
+}
+
+// clientIP is the address of the peer. Behind a reverse proxy that is the
+// proxy, as forwarding headers can not be trusted in general.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func truncateUserAgent(userAgent string) string {
+	if len(userAgent) <= maxUserAgentLength {
+		return userAgent
+	}
+	userAgent = userAgent[:maxUserAgentLength]
+	for !utf8.ValidString(userAgent) {
+		userAgent = userAgent[:len(userAgent)-1]
+	}
+	return userAgent
+}
+
+// parseDeviceLabel validates the label a client sent at login, or derives
+// one such as "Firefox on Linux" from the user agent.
+func parseDeviceLabel(label, userAgent string) (string, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return deviceLabel(userAgent), nil
+	}
+	if utf8.RuneCountInString(label) > maxDeviceLabelLength {
+		return "", errors.New("Device label is to long")
+	}
+	if strings.ContainsFunc(label, unicode.IsControl) {
+		return "", errors.New("Device label contains invalid characters")
+	}
+	return label, nil
+}
+
+func deviceLabel(userAgent string) string {
+	// Order matters: Edge and Opera also claim to be Chrome, and Chrome
+	// claims to be Safari. Likewise iOS user agents mention Mac OS X.
+	browser := ""
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	platform := ""
+	for _, o := range []struct{ token, name string }{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, o.token) {
+			platform = o.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	return "Unknown device"
+}
+
+func (cfg *apiConfig) handlerGetSessions(w http.ResponseWriter, r *http.Request) {
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	rows, err := cfg.db.ListSessions(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 400, "Error getting sessions")
+		return
+	}
+
+	sessions := make([]Session, 0, len(rows))
+	for _, v := range rows {
+		sessions = append(sessions, sessionFromDB(v))
+	}
+
+	respondWithJSON(w, 200, sessions)
+}
+
+func (cfg *apiConfig) handlerDeleteSession(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	sessionId, err := uuid.Parse(r.PathValue("sessionId"))
+	if err != nil {
+		respondWithError(w, 404, "Session not found")
+		return
+	}
+
+	deleted, err := cfg.endSessions(userId, uuid.NullUUID{UUID: sessionId, Valid: true})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: delete session: "+err.Error())
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "Session not found")
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// handlerDeleteSessions logs the user out everywhere, including the client
+// making the request.
+func (cfg *apiConfig) handlerDeleteSessions(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	_, err = cfg.endSessions(userId, uuid.NullUUID{})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: delete sessions: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// sweepSessions deletes expired sessions along with their refresh tokens,
+// and expired login challenges. Used tokens are kept until then so that
+// replaying them is still detected.
+func (cfg *apiConfig) sweepSessions(interval time.Duration) {
+	for range time.Tick(interval) {
+		_, err := cfg.db.DeleteExpiredSessions(context.Background())
+		if err != nil {
+			log.Printf("Error deleting expired sessions: %s\n", err)
+		}
+		_, err = cfg.db.DeleteExpiredMFAChallenges(context.Background())
+		if err != nil {
+			log.Printf("Error deleting expired login challenges: %s\n", err)
+		}
+	}
+}
+
+// endSessions deletes one session of a user, or all of them when sessionId
+// is null. Access tokens name their session, so those of an ended session
+// stop working with it. Logging out everywhere also bumps the user's token
+// version, which revokes every access token at once.
+func (cfg *apiConfig) endSessions(userId uuid.UUID, sessionId uuid.NullUUID) (int64, error) {
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	var deleted int64
+	if sessionId.Valid {
+		deleted, err = q.DeleteSession(context.Background(), database.DeleteSessionParams{
+			ID:     sessionId.UUID,
+			UserID: userId,
+		})
+	} else {
+		deleted, err = q.DeleteUserSessions(context.Background(), userId)
+	}
+	if err != nil || deleted == 0 {
+		return deleted, err
+	}
+
+	if !sessionId.Valid {
+		_, err = q.BumpTokenVersion(context.Background(), userId)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	cfg.tokenVersions.Invalidate(userId)
+
+	return deleted, nil
+}
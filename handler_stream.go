@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/stream"
+)
+
+const (
+	chirpEventCreated = "chirp.created"
+	chirpEventDeleted = "chirp.deleted"
+
+	chirpEventRetention = 24 * time.Hour
+	chirpEventPage      = 100
+	streamHeartbeat     = 15 * time.Second
+	streamBuffer        = 64
+)
+
+type chirpDeletedEvent struct {
+	ID     uuid.UUID `json:"id"`
+	UserId uuid.UUID `json:"user_id"`
+}
+
+// recordChirpEvent stores a chirp event. The insert notifies every server
+// instance listening on chirp_events, including this one.
+func (cfg *apiConfig) recordChirpEvent(eventType string, c database.Chirp) {
+	err := cfg.db.CreateChirpEvent(context.Background(), database.CreateChirpEventParams{
+		Type:     eventType,
+		ChirpID:  c.ID,
+		AuthorID: c.UserID,
+		RootID:   c.RootID,
+	})
+	if err != nil {
+		log.Printf("Error recording %s event for chirp %s: %s\n", eventType, c.ID, err)
+	}
+}
+
+// streamEvents turns stored chirp events into the JSON clients receive.
+// Created events for chirps that have been deleted since are skipped.
+func (cfg *apiConfig) streamEvents(rows []database.ChirpEvent) ([]stream.Event, error) {
+	ids := []uuid.UUID{}
+	for _, e := range rows {
+		if e.Type == chirpEventCreated {
+			ids = append(ids, e.ChirpID)
+		}
+	}
+
+	byId := map[uuid.UUID]Chirpy{}
+	if len(ids) > 0 {
+		chirps, err := cfg.db.GetChirpsByIDs(context.Background(), ids)
+		if err != nil {
+			return nil, err
+		}
+		decorated, err := cfg.chirpsFromDB(uuid.NullUUID{}, chirps)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range decorated {
+			byId[c.ID] = c
+		}
+	}
+
+	events := make([]stream.Event, 0, len(rows))
+	for _, e := range rows {
+		var payload interface{}
+		threadId := uuid.NullUUID{}
+		switch e.Type {
+		case chirpEventCreated:
+			c, ok := byId[e.ChirpID]
+			if !ok {
+				continue
+			}
+			payload = c
+			if c.ThreadID != nil {
+				threadId = uuid.NullUUID{UUID: *c.ThreadID, Valid: true}
+			}
+		case chirpEventDeleted:
+			payload = chirpDeletedEvent{ID: e.ChirpID, UserId: e.AuthorID}
+			// Deleting the root takes the thread down with it, so its
+			// subscribers are told as well.
+			threadId = e.RootID
+			if !threadId.Valid {
+				threadId = uuid.NullUUID{UUID: e.ChirpID, Valid: true}
+			}
+		default:
+			continue
+		}
+
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, stream.Event{
+			ID:       e.ID,
+			Tx:       e.TxID,
+			Type:     e.Type,
+			UserID:   e.AuthorID,
+			ThreadID: threadId,
+			Data:     data,
+		})
+	}
+
+	return events, nil
+}
+
+// listenEvents publishes chirp events and notifications from every server
+// instance to the local hubs, and drops changed token versions from the
+// local cache. A chirp event notification only says that something
+// happened: events are read back from chirp_events in id order, which also
+// picks up anything missed while the listener was reconnecting.
+func (cfg *apiConfig) listenEvents(dbUrl string) {
+	listener := pq.NewListener(dbUrl, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Chirp event listener: %s\n", err)
+		}
+		// Token versions may have changed unnoticed while disconnected.
+		if ev == pq.ListenerEventReconnected {
+			cfg.tokenVersions.InvalidateAll()
+		}
+	})
+	for _, channel := range []string{"chirp_events", "notifications", "token_versions"} {
+		listen(listener, channel)
+	}
+
+	// Events are held back while an older transaction is still open, and
+	// that transaction may not notify when it ends, so the log is polled.
+	poll := time.NewTicker(5 * time.Second)
+	defer poll.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+
+	// Only events created after startup are published; clients resuming
+	// from older ones replay them from the table themselves.
+	var last *eventCursor
+	for {
+		if last == nil {
+			horizon, err := cfg.db.GetChirpEventHorizon(context.Background())
+			if err != nil {
+				log.Printf("Error getting chirp event horizon: %s\n", err)
+			} else {
+				last = &eventCursor{tx: horizon - 1, id: math.MaxInt64}
+			}
+		} else {
+			*last = cfg.publishChirpEventsAfter(*last)
+		}
+
+		select {
+		case n := <-listener.Notify:
+			if n != nil && n.Channel == "notifications" {
+				cfg.publishNotification(n.Extra)
+				continue
+			}
+			if n != nil && n.Channel == "token_versions" {
+				userId, err := uuid.Parse(n.Extra)
+				if err == nil {
+					cfg.tokenVersions.Invalidate(userId)
+				}
+				continue
+			}
+		case <-poll.C:
+		case <-prune.C:
+			_, err := cfg.db.DeleteChirpEventsBefore(context.Background(), time.Now().UTC().Add(-chirpEventRetention))
+			if err != nil {
+				log.Printf("Error pruning chirp events: %s\n", err)
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) publishNotification(id string) {
+	notificationId, err := uuid.Parse(id)
+	if err != nil {
+		return
+	}
+
+	n, err := cfg.db.GetNotification(context.Background(), notificationId)
+	if err != nil {
+		log.Printf("Error reading notification %s: %s\n", id, err)
+		return
+	}
+	data, err := json.Marshal(notificationFromDB(n))
+	if err != nil {
+		return
+	}
+
+	cfg.notificationStream.Publish(stream.Event{
+		Type:   "notification",
+		UserID: n.UserID,
+		Data:   data,
+	})
+}
+
+// listen subscribes the listener to a channel, retrying until it succeeds.
+// Without it this instance would miss chirp events until the next poll, and
+// token version changes made on other instances until their cache expires.
+func listen(listener *pq.Listener, channel string) {
+	backoff := time.Second
+	for {
+		err := listener.Listen(channel)
+		if err == nil || errors.Is(err, pq.ErrChannelAlreadyOpen) {
+			return
+		}
+		log.Printf("Error listening for %s, retrying in %s: %s\n", channel, backoff, err)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, time.Minute)
+	}
+}
+
+func (cfg *apiConfig) publishChirpEventsAfter(last eventCursor) eventCursor {
+	for {
+		rows, err := cfg.db.ListChirpEventsAfter(context.Background(), database.ListChirpEventsAfterParams{
+			AfterTxID: last.tx,
+			AfterID:   last.id,
+			PageLimit: chirpEventPage,
+		})
+		if err != nil {
+			log.Printf("Error reading chirp events: %s\n", err)
+			return last
+		}
+		events, err := cfg.streamEvents(rows)
+		if err != nil {
+			log.Printf("Error reading chirp events: %s\n", err)
+			return last
+		}
+
+		for _, e := range events {
+			cfg.chirpStream.Publish(e)
+		}
+		if len(rows) > 0 {
+			last = eventCursor{tx: rows[len(rows)-1].TxID, id: rows[len(rows)-1].ID}
+		}
+		if len(rows) < chirpEventPage {
+			return last
+		}
+	}
+}
+
+// eventCursor is a position in the chirp event log. Clients see it as the
+// SSE event id "<tx>-<id>".
+type eventCursor struct {
+	tx int64
+	id int64
+}
+
+func parseEventCursor(s string) (eventCursor, error) {
+	tx, id, ok := strings.Cut(s, "-")
+	if !ok {
+		return eventCursor{}, errors.New("Last-Event-ID must be an event id")
+	}
+	c := eventCursor{}
+	var err error
+	c.tx, err = strconv.ParseInt(tx, 10, 64)
+	if err != nil {
+		return eventCursor{}, errors.New("Last-Event-ID must be an event id")
+	}
+	c.id, err = strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return eventCursor{}, errors.New("Last-Event-ID must be an event id")
+	}
+	return c, nil
+}
+
+func (c eventCursor) String() string {
+	return fmt.Sprintf("%d-%d", c.tx, c.id)
+}
+
+// before reports whether e comes after the cursor.
+func (c eventCursor) before(e stream.Event) bool {
+	return c.tx < e.Tx || (c.tx == e.Tx && c.id < e.ID)
+}
+
+func (cfg *apiConfig) handlerStreamChirps(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, 500, "Streaming unsupported")
+		return
+	}
+
+	authorId := uuid.NullUUID{}
+	if a := r.URL.Query().Get("author_id"); a != "" {
+		userId, err := uuid.Parse(a)
+		if err != nil {
+			respondWithError(w, 400, "author_id must be a user id")
+			return
+		}
+		authorId = uuid.NullUUID{UUID: userId, Valid: true}
+	}
+
+	// Browsers send Last-Event-ID when they reconnect; the query parameter
+	// lets a client resume on its first connection too.
+	lastEventId := r.Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = r.URL.Query().Get("last_event_id")
+	}
+	last := eventCursor{}
+	resume := lastEventId != ""
+	if resume {
+		var err error
+		last, err = parseEventCursor(lastEventId)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+	}
+
+	// Subscribe before replaying so nothing published in between is lost;
+	// the replayed events are skipped when they come through the hub.
+	sub := cfg.chirpStream.Subscribe(streamBuffer, func(e stream.Event) bool {
+		return !authorId.Valid || e.UserID == authorId.UUID
+	})
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(200)
+	flusher.Flush()
+
+	for resume {
+		rows, err := cfg.db.ListChirpEventsAfter(context.Background(), database.ListChirpEventsAfterParams{
+			AfterTxID: last.tx,
+			AfterID:   last.id,
+			AuthorID:  authorId,
+			PageLimit: chirpEventPage,
+		})
+		if err != nil {
+			return
+		}
+		events, err := cfg.streamEvents(rows)
+		if err != nil {
+			return
+		}
+		for _, e := range events {
+			if writeStreamEvent(w, e) != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		if len(rows) > 0 {
+			last = eventCursor{tx: rows[len(rows)-1].TxID, id: rows[len(rows)-1].ID}
+		}
+		resume = len(rows) == chirpEventPage
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind, the client resumes with
+				// Last-Event-ID.
+				return
+			}
+			if !last.before(e) {
+				continue
+			}
+			if writeStreamEvent(w, e) != nil {
+				return
+			}
+			last = eventCursor{tx: e.Tx, id: e.ID}
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(w, ": ping\n\n")
+			if err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, e stream.Event) error {
+	id := eventCursor{tx: e.Tx, id: e.ID}
+	_, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, e.Type, e.Data)
+	return err
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/vystepanenko/Chirpy/internal/auth"
-	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/notifications"
 )
 
 func (cfg *apiConfig) handlerSubscription(w http.ResponseWriter, r *http.Request) {
@@ -51,15 +51,25 @@ func (cfg *apiConfig) handlerSubscription(w http.ResponseWriter, r *http.Request
 		respondWithError(w, 400, "Something went wrong")
 		return
 	}
-	uiParams := database.UpdateChirpyRedParams{
-		IsChirpyRed: true,
-		ID:          userId,
-	}
-	_, err = cfg.db.UpdateChirpyRed(context.Background(), uiParams)
+	_, err = cfg.db.GetUser(context.Background(), userId)
 	if err != nil {
 		w.WriteHeader(404)
 		return
 	}
+	upgraded, err := cfg.db.UpgradeToChirpyRed(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong")
+		return
+	}
+
+	// Polka retries webhooks, so only the call that actually upgrades the
+	// user notifies them.
+	if upgraded > 0 {
+		cfg.notifications.Emit(notifications.Event{
+			Type:        notifications.TypeSubscription,
+			RecipientID: userId,
+		})
+	}
 
 	w.WriteHeader(204)
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/textparse"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+	defaultTrendingLimit  = 10
+)
+
+type TrendingTag struct {
+	Tag   string  `json:"tag"`
+	Uses  int64   `json:"uses"`
+	Score float64 `json:"score"`
+}
+
+// tagChirp stores the hashtags of a chirp body, replacing any it had before.
+func tagChirp(q *database.Queries, chirpId uuid.UUID, body string) error {
+	err := q.ClearChirpTags(context.Background(), chirpId)
+	if err != nil {
+		return err
+	}
+
+	tags := textparse.Hashtags(body)
+	if len(tags) == 0 {
+		return nil
+	}
+
+	return q.TagChirp(context.Background(), database.TagChirpParams{
+		Names:   tags,
+		ChirpID: chirpId,
+	})
+}
+
+func (cfg *apiConfig) handlerGetTagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+	if tag == "" {
+		respondWithError(w, 422, "Please provide tag")
+		return
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorId := page.cursorParams()
+
+	// Newest chirps first.
+	var chirps []database.Chirp
+	if !page.backward {
+		chirps, err = cfg.db.ListTagChirpsDesc(context.Background(), database.ListTagChirpsDescParams{
+			Name:            tag,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	} else {
+		chirps, err = cfg.db.ListTagChirpsAsc(context.Background(), database.ListTagChirpsAscParams{
+			Name:            tag,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, 400, "Error getting chirps")
+		return
+	}
+
+	chirps, prev, next := paginate(chirps, page, chirpCursor)
+
+	tagged, err := cfg.chirpsFromDB(cfg.optionalViewer(r), chirps)
+	if err != nil {
+		respondWithError(w, 400, "Error getting chirps")
+		return
+	}
+
+	setPageHeaders(w, r, prev, next)
+	respondWithJSON(w, 200, tagged)
+}
+
+// handlerGetTrending ranks tags used within the window. Each use decays with
+// a time constant of a quarter of the window, so recent activity dominates.
+func (cfg *apiConfig) handlerGetTrending(w http.ResponseWriter, r *http.Request) {
+	window := defaultTrendingWindow
+	if v := r.URL.Query().Get("window"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < time.Minute || parsed > maxTrendingWindow {
+			respondWithError(w, 400, "window must be a duration between 1m and "+maxTrendingWindow.String())
+			return
+		}
+		window = parsed
+	}
+
+	limit := defaultTrendingLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed < 1 || parsed > maxPageLimit {
+			respondWithError(w, 400, "limit must be between 1 and "+strconv.Itoa(maxPageLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	rows, err := cfg.db.TrendingTags(context.Background(), database.TrendingTagsParams{
+		DecaySeconds:  (window / 4).Seconds(),
+		WindowSeconds: window.Seconds(),
+		PageLimit:     int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, 400, "Error getting trending tags")
+		return
+	}
+
+	trending := make([]TrendingTag, 0, len(rows))
+	for _, v := range rows {
+		trending = append(trending, TrendingTag{
+			Tag:   v.Name,
+			Uses:  v.Uses,
+			Score: v.Score,
+		})
+	}
+
+	respondWithJSON(w, 200, trending)
+}
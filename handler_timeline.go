@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+)
+
+func (cfg *apiConfig) handlerGetTimeline(w http.ResponseWriter, r *http.Request) {
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	cursorCreatedAt, cursorId := page.cursorParams()
+
+	// The timeline reads newest first.
+	var chirps []database.Chirp
+	if !page.backward {
+		chirps, err = cfg.db.ListTimelineDesc(context.Background(), database.ListTimelineDescParams{
+			UserID:          userId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	} else {
+		chirps, err = cfg.db.ListTimelineAsc(context.Background(), database.ListTimelineAscParams{
+			UserID:          userId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorId,
+			PageLimit:       page.queryLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, 400, "Error getting timeline")
+		return
+	}
+
+	chirps, prev, next := paginate(chirps, page, chirpCursor)
+
+	timeline, err := cfg.chirpsFromDB(uuid.NullUUID{UUID: userId, Valid: true}, chirps)
+	if err != nil {
+		respondWithError(w, 400, "Error getting timeline")
+		return
+	}
+
+	setPageHeaders(w, r, prev, next)
+	respondWithJSON(w, 200, timeline)
+}
+
+// fanOutChirp copies a new chirp into the timelines of its author and
+// followers. It runs in the background so accounts with many followers
+// do not slow down chirp creation.
+func (cfg *apiConfig) fanOutChirp(chirpId uuid.UUID) {
+	go func() {
+		err := cfg.db.FanOutChirp(context.Background(), chirpId)
+		if err != nil {
+			log.Printf("Error fanning out chirp %s: %s\n", chirpId, err)
+		}
+	}()
+}
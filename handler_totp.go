@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+)
+
+const (
+	totpIssuer              = "Chirpy"
+	recoveryCodeCount       = 10
+	mfaChallengeTTL         = 5 * time.Minute
+	maxMFAChallengeAttempts = 5
+)
+
+var errTOTPLocked = errors.New("Too many invalid codes, try again later")
+
+// totpAAD binds an encrypted TOTP secret to its user.
+func totpAAD(userId uuid.UUID) []byte {
+	return []byte("totp:" + userId.String())
+}
+
+// handlerEnrollTOTP starts setting up two-factor authentication. It only
+// becomes active once a code from the authenticator is verified.
+func (cfg *apiConfig) handlerEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	type requestBody struct {
+		Password string `json:"password"`
+	}
+
+	type responseBody struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri"`
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: body")
+		return
+	}
+
+	params := requestBody{}
+	err = json.Unmarshal(dat, &params)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: unmarshal: "+err.Error())
+		return
+	}
+
+	u, err := cfg.db.GetUser(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: user")
+		return
+	}
+	err = auth.CheckPasswordHash(params.Password, u.HashedPassword)
+	if err != nil {
+		respondWithError(w, 403, "Password is incorrect")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: secret: "+err.Error())
+		return
+	}
+	sealed, err := cfg.secrets.Seal([]byte(secret), totpAAD(userId))
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: secret: "+err.Error())
+		return
+	}
+
+	created, err := cfg.db.CreateTOTPSecret(context.Background(), database.CreateTOTPSecretParams{
+		UserID: userId,
+		Secret: sealed,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: enroll: "+err.Error())
+		return
+	}
+	if created == 0 {
+		respondWithError(w, 409, "Two-factor authentication is already enabled")
+		return
+	}
+
+	respondWithJSON(w, 200, responseBody{
+		Secret: secret,
+		URI:    auth.TOTPURI(secret, totpIssuer, u.Email),
+	})
+}
+
+// handlerVerifyTOTP activates two-factor authentication with a first code
+// and hands out the recovery codes. They are only ever shown here.
+func (cfg *apiConfig) handlerVerifyTOTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	type requestBody struct {
+		Code string `json:"code"`
+	}
+
+	type responseBody struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: body")
+		return
+	}
+
+	params := requestBody{}
+	err = json.Unmarshal(dat, &params)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: unmarshal: "+err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: begin: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	t, err := q.GetUserTOTPForUpdate(context.Background(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Two-factor authentication is not set up")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: totp: "+err.Error())
+		return
+	}
+	if t.EnabledAt.Valid {
+		respondWithError(w, 409, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := cfg.secrets.Open(t.Secret, totpAAD(userId))
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: secret: "+err.Error())
+		return
+	}
+	step, ok := auth.ValidateTOTP(string(secret), params.Code, time.Now())
+	if !ok {
+		respondWithError(w, 400, "Invalid code")
+		return
+	}
+
+	_, err = q.EnableTOTP(context.Background(), database.EnableTOTPParams{
+		UserID:   userId,
+		LastStep: step,
+	})
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: enable: "+err.Error())
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: recovery codes: "+err.Error())
+		return
+	}
+	err = q.DeleteRecoveryCodes(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: recovery codes: "+err.Error())
+		return
+	}
+	for _, c := range codes {
+		err = q.CreateRecoveryCode(context.Background(), database.CreateRecoveryCodeParams{
+			UserID:   userId,
+			CodeHash: auth.HashToken(c),
+		})
+		if err != nil {
+			respondWithError(w, 400, "Something goes wrong: recovery codes: "+err.Error())
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: commit: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, responseBody{RecoveryCodes: codes})
+}
+
+// handlerDisableTOTP turns two-factor authentication off. An active one
+// needs a code as well as the password, so an access token alone is not
+// enough to remove it.
+func (cfg *apiConfig) handlerDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	type requestBody struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: body")
+		return
+	}
+
+	params := requestBody{}
+	err = json.Unmarshal(dat, &params)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: unmarshal: "+err.Error())
+		return
+	}
+
+	u, err := cfg.db.GetUser(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: user")
+		return
+	}
+	err = auth.CheckPasswordHash(params.Password, u.HashedPassword)
+	if err != nil {
+		respondWithError(w, 403, "Password is incorrect")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: begin: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	t, err := q.GetUserTOTPForUpdate(context.Background(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Two-factor authentication is not set up")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: totp: "+err.Error())
+		return
+	}
+
+	if t.EnabledAt.Valid {
+		ok, err := cfg.verifySecondFactor(q, t, params.Code)
+		if errors.Is(err, errTOTPLocked) {
+			respondWithError(w, 429, err.Error())
+			return
+		}
+		if err != nil {
+			respondWithError(w, 400, "Something goes wrong: verify: "+err.Error())
+			return
+		}
+		if !ok {
+			err = tx.Commit()
+			if err != nil {
+				respondWithError(w, 400, "Something goes wrong: commit: "+err.Error())
+				return
+			}
+			respondWithError(w, 403, "Invalid code")
+			return
+		}
+	}
+
+	err = q.DeleteUserTOTP(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: disable: "+err.Error())
+		return
+	}
+	err = q.DeleteRecoveryCodes(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: recovery codes: "+err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 400, "Something goes wrong: commit: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// handlerLoginMFA is the second login step. It exchanges the challenge token
+// from handlerUserLogin and a TOTP or recovery code for the tokens a login
+// without two-factor authentication returns right away.
+func (cfg *apiConfig) handlerLoginMFA(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	type requestBody struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: body")
+		return
+	}
+
+	params := requestBody{}
+	err = json.Unmarshal(dat, &params)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: unmarshal: "+err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: begin: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	tokenHash := auth.HashToken(params.MFAToken)
+	ch, err := q.GetMFAChallengeForUpdate(context.Background(), tokenHash)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	t, err := q.GetUserTOTPForUpdate(context.Background(), ch.UserID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 401, "Unauthorized: totp: "+err.Error())
+		return
+	}
+	// Two-factor authentication was turned off after the password step.
+	if err != nil || !t.EnabledAt.Valid {
+		err = q.DeleteMFAChallenge(context.Background(), tokenHash)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			respondWithError(w, 401, "Unauthorized: challenge: "+err.Error())
+			return
+		}
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	ok, err := cfg.verifySecondFactor(q, t, params.Code)
+	if errors.Is(err, errTOTPLocked) {
+		respondWithError(w, 429, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: verify: "+err.Error())
+		return
+	}
+
+	// A challenge takes a few attempts, after that the password step has
+	// to be repeated.
+	if !ok {
+		attempts, err := q.RecordMFAChallengeFailure(context.Background(), tokenHash)
+		if err == nil && attempts >= maxMFAChallengeAttempts {
+			err = q.DeleteMFAChallenge(context.Background(), tokenHash)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			respondWithError(w, 401, "Unauthorized: challenge: "+err.Error())
+			return
+		}
+		respondWithError(w, 401, "Invalid code")
+		return
+	}
+
+	err = q.DeleteMFAChallenge(context.Background(), tokenHash)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: challenge: "+err.Error())
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: commit: "+err.Error())
+		return
+	}
+
+	user, err := cfg.db.GetUser(context.Background(), ch.UserID)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: user")
+		return
+	}
+	if user.BannedAt.Valid {
+		respondWithError(w, 403, "Account is banned")
+		return
+	}
+
+	cfg.completeLogin(w, r, user, ch.DeviceLabel)
+}
+
+// createMFAChallenge records that a user got the password right and returns
+// the token for the second login step. Only its hash is stored.
+func (cfg *apiConfig) createMFAChallenge(userId uuid.UUID, label string) (string, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = cfg.db.CreateMFAChallenge(context.Background(), database.CreateMFAChallengeParams{
+		TokenHash:   auth.HashToken(token),
+		UserID:      userId,
+		DeviceLabel: label,
+		ExpiresAt:   time.Now().Add(mfaChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// verifySecondFactor accepts a TOTP code of a time step after the last one
+// used, or an unused recovery code, and uses it up within q's transaction.
+// Wrong codes are counted, and too many in a row lock the user out for a
+// while.
+func (cfg *apiConfig) verifySecondFactor(q *database.Queries, t database.GetUserTOTPForUpdateRow, code string) (bool, error) {
+	if t.Locked {
+		return false, errTOTPLocked
+	}
+
+	secret, err := cfg.secrets.Open(t.Secret, totpAAD(t.UserID))
+	if err != nil {
+		return false, err
+	}
+
+	var used int64
+	if step, ok := auth.ValidateTOTP(string(secret), code, time.Now()); ok {
+		used, err = q.UseTOTPStep(context.Background(), database.UseTOTPStepParams{
+			UserID:   t.UserID,
+			LastStep: step,
+		})
+	} else {
+		used, err = q.UseRecoveryCode(context.Background(), database.UseRecoveryCodeParams{
+			UserID:   t.UserID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if used == 0 {
+		return false, q.RecordTOTPFailure(context.Background(), t.UserID)
+	}
+	return true, q.ResetTOTPFailures(context.Background(), t.UserID)
+}
@@ -2,31 +2,102 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"io"
+	"log"
 	"net/http"
+	"net/mail"
 	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/vystepanenko/Chirpy/internal/auth"
 	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/mailer"
+	"github.com/vystepanenko/Chirpy/internal/textparse"
 )
 
+const emailChangeTTL = 24 * time.Hour
+
 type User struct {
 	ID          uuid.UUID `json:"id"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	Email       string    `json:"email"`
+	Handle      string    `json:"handle,omitempty"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	Location    string    `json:"location"`
+	Website     string    `json:"website"`
 	IsChirpyRed bool      `json:"is_chirpy_red"`
 }
 
+func userFromDB(u database.User) User {
+	return User{
+		ID:          u.ID,
+		Email:       u.Email,
+		Handle:      u.Handle.String,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarUrl,
+		Location:    u.Location,
+		Website:     u.Website,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		IsChirpyRed: u.IsChirpyRed,
+	}
+}
+
+// parseHandle validates an optional handle from a request body.
+func parseHandle(handle string) (sql.NullString, error) {
+	if handle == "" {
+		return sql.NullString{}, nil
+	}
+
+	err := textparse.ValidateHandle(handle)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: handle, Valid: true}, nil
+}
+
+// parseHandleUpdate validates a handle a user wants to change to, for both
+// PUT /api/users and PATCH /api/users/me. A handle left out of the body stays
+// null and the current one is kept; unlike at sign up it can not be cleared.
+func parseHandleUpdate(handle *string) (sql.NullString, error) {
+	if handle == nil {
+		return sql.NullString{}, nil
+	}
+
+	err := textparse.ValidateHandle(*handle)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: *handle, Valid: true}, nil
+}
+
+// respondIfHandleTaken answers with 409 when err is a handle another user
+// already has, and reports whether it did.
+func respondIfHandleTaken(w http.ResponseWriter, err error) bool {
+	if !isUniqueViolation(err, "users_handle_lower_idx") {
+		return false
+	}
+	respondWithError(w, 409, "Handle is already taken")
+	return true
+}
+
 func (cfg *apiConfig) handlerUserCreate(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	type requestBody struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 
 	dat, err := io.ReadAll(r.Body)
@@ -42,6 +113,12 @@ func (cfg *apiConfig) handlerUserCreate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	handle, err := parseHandle(params.Handle)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(w, 400, "Something went wrong: hashing password: "+err.Error())
@@ -51,36 +128,33 @@ func (cfg *apiConfig) handlerUserCreate(w http.ResponseWriter, r *http.Request)
 	uParams := database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
+		Handle:         handle,
 	}
 
 	u, err := cfg.db.CreateUser(context.Background(), uParams)
+	if respondIfHandleTaken(w, err) {
+		return
+	}
 	if err != nil {
 		respondWithError(w, 400, err.Error())
 		return
 	}
 
-	respondWithJSON(w, 201, User{
-		ID:          u.ID,
-		Email:       u.Email,
-		CreatedAt:   u.CreatedAt,
-		UpdatedAt:   u.UpdatedAt,
-		IsChirpyRed: u.IsChirpyRed,
-	},
-	)
+	respondWithJSON(w, 201, userFromDB(u))
 }
 
 func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	type requestBody struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email       string `json:"email"`
+		Password    string `json:"password"`
+		DeviceLabel string `json:"device_label"`
 	}
 
-	type responseBody struct {
-		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
+	type mfaResponseBody struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
 	}
 
 	dat, err := io.ReadAll(r.Body)
@@ -107,38 +181,63 @@ func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, 401, "Unauthorized")
 		return
 	}
+	if user.BannedAt.Valid {
+		respondWithError(w, 403, "Account is banned")
+		return
+	}
 
-	accessToken, err := auth.MakeJWT(user.ID, cfg.secretKey, time.Hour)
+	label, err := parseDeviceLabel(params.DeviceLabel, r.UserAgent())
 	if err != nil {
-		respondWithError(w, 401, "Unauthorized")
+		respondWithError(w, 400, err.Error())
 		return
 	}
 
-	refreshToken, err := auth.MakeRefreshToken()
-	if err != nil {
-		respondWithError(w, 401, "Unauthorized: refresh")
+	// With two-factor authentication the tokens are only issued by
+	// handlerLoginMFA, in exchange for the challenge and a code.
+	t, err := cfg.db.GetUserTOTP(context.Background(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 401, "Unauthorized: totp: "+err.Error())
 		return
 	}
+	if err == nil && t.EnabledAt.Valid {
+		mfaToken, err := cfg.createMFAChallenge(user.ID, label)
+		if err != nil {
+			respondWithError(w, 401, "Unauthorized: challenge: "+err.Error())
+			return
+		}
+		respondWithJSON(w, 200, mfaResponseBody{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		})
+		return
+	}
+
+	cfg.completeLogin(w, r, user, label)
+}
 
-	rtParams := database.CreateRefreshTokenParams{
-		Token:  refreshToken,
-		UserID: user.ID,
+// completeLogin starts a session for a user that passed every login step
+// and responds with the user and their tokens.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User, label string) {
+	type responseBody struct {
+		User
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
-	_, err = cfg.db.CreateRefreshToken(context.Background(), rtParams)
+	sessionId, refreshToken, err := cfg.startSession(r, user.ID, label)
 	if err != nil {
 		respondWithError(w, 401, "Unauthorized: refresh_save: "+err.Error())
 		return
 	}
 
+	accessToken, err := auth.MakeJWT(user.ID, sessionId, user.TokenVersion, cfg.jwtKeys, time.Hour)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
 	respondWithJSON(w, 200, responseBody{
-		User: User{
-			ID:          user.ID,
-			Email:       user.Email,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			IsChirpyRed: user.IsChirpyRed,
-		},
+		User:         userFromDB(user),
 		Token:        accessToken,
 		RefreshToken: refreshToken,
 	},
@@ -154,23 +253,88 @@ func (cfg *apiConfig) handlerRefreshToken(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	rt, err := cfg.db.GetRefreshToken(context.Background(), bar)
+	rt, err := cfg.db.GetRefreshToken(context.Background(), auth.HashToken(bar))
 	if err != nil {
 		respondWithError(w, 401, "Unauthorized: get from db: "+err.Error())
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(rt.UserID, cfg.secretKey, time.Hour)
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: begin: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	used, err := q.UseRefreshToken(context.Background(), rt.TokenHash)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: use: "+err.Error())
+		return
+	}
+
+	// A used token coming back means it leaked, either to whoever sent it
+	// now or to whoever refreshed with it first. Ending the session, and
+	// with it its access tokens, logs both out.
+	if used == 0 {
+		_, err = q.DeleteSession(context.Background(), database.DeleteSessionParams{
+			ID:     rt.SessionID,
+			UserID: rt.UserID,
+		})
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			respondWithError(w, 401, "Unauthorized: revoke: "+err.Error())
+			return
+		}
+		cfg.tokenVersions.Invalidate(rt.UserID)
+		respondWithError(w, 401, "Unauthorized: refresh token reused")
+		return
+	}
+
+	// Banned users have no token version.
+	version, err := q.GetUserTokenVersion(context.Background(), rt.UserID)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: user: "+err.Error())
+		return
+	}
+
+	err = q.TouchSession(context.Background(), database.TouchSessionParams{
+		ID:        rt.SessionID,
+		UserAgent: truncateUserAgent(r.UserAgent()),
+		Ip:        clientIP(r),
+	})
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: session: "+err.Error())
+		return
+	}
+
+	refreshToken, err := issueRefreshToken(q, rt.UserID, rt.SessionID)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: refresh_save: "+err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized: commit: "+err.Error())
+		return
+	}
+
+	accessToken, err := auth.MakeJWT(rt.UserID, rt.SessionID, version, cfg.jwtKeys, time.Hour)
 	if err != nil {
 		respondWithError(w, 401, "Unauthorized: make jwt")
 		return
 	}
 
 	type responseBody struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 	respondWithJSON(w, 200, responseBody{
-		Token: accessToken,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
 	})
 }
 
@@ -183,13 +347,15 @@ func (cfg *apiConfig) handlerRefreshTokenRevoke(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	rt, err := cfg.db.GetRefreshToken(context.Background(), bar)
+	rt, err := cfg.db.GetRefreshToken(context.Background(), auth.HashToken(bar))
 	if err != nil {
 		respondWithError(w, 401, "Unauthorized")
 		return
 	}
 
-	err = cfg.db.RevokeRefreshToken(context.Background(), rt.Token)
+	// Earlier tokens of the session may still be in a client's hands, so
+	// the whole session ends, not just this token.
+	_, err = cfg.endSessions(rt.UserID, uuid.NullUUID{UUID: rt.SessionID, Valid: true})
 	if err != nil {
 		respondWithError(w, 401, "Unauthorized: revoke: "+err.Error())
 		return
@@ -198,6 +364,29 @@ func (cfg *apiConfig) handlerRefreshTokenRevoke(w http.ResponseWriter, r *http.R
 	w.WriteHeader(204)
 }
 
+// issueRefreshToken creates a refresh token in the given session. Only its
+// hash is stored; the token itself is returned to be handed to the client.
+func issueRefreshToken(q *database.Queries, userId, sessionId uuid.UUID) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+		TokenHash: auth.HashToken(refreshToken),
+		UserID:    userId,
+		SessionID: sessionId,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return refreshToken, nil
+}
+
+// handlerUpdateUserInfo updates only the fields present in the body. A new
+// password needs the current one, and a new email is only saved once it has
+// been confirmed with the token mailed to it.
 func (cfg *apiConfig) handlerUpdateUserInfo(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
@@ -206,15 +395,24 @@ func (cfg *apiConfig) handlerUpdateUserInfo(w http.ResponseWriter, r *http.Reque
 		respondWithError(w, 401, "Unauthorized_bar")
 		return
 	}
-	userId, err := auth.ValidateJWT(bar, cfg.secretKey)
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
 	if err != nil {
 		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
 		return
 	}
 
 	type requestBody struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
+		Handle          *string `json:"handle"`
+	}
+
+	type responseBody struct {
+		User
+		PendingEmail string `json:"pending_email,omitempty"`
+		Token        string `json:"token,omitempty"`
+		RefreshToken string `json:"refresh_token,omitempty"`
 	}
 
 	dat, err := io.ReadAll(r.Body)
@@ -230,29 +428,239 @@ func (cfg *apiConfig) handlerUpdateUserInfo(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(params.Password)
+	u, err := cfg.db.GetUser(context.Background(), userId)
 	if err != nil {
-		respondWithError(w, 400, "Something went wrong: hashing password: "+err.Error())
+		respondWithError(w, 401, "Unauthorized: user")
 		return
 	}
 
-	uuParams := database.UpdateUserInfoParams{
-		ID:             userId,
-		Email:          params.Email,
-		HashedPassword: hashedPassword,
+	hashedPassword, refreshToken := "", ""
+	sessionId := uuid.UUID{}
+	if params.Password != nil {
+		if *params.Password == "" {
+			respondWithError(w, 400, "Password can not be empty")
+			return
+		}
+		err = auth.CheckPasswordHash(params.CurrentPassword, u.HashedPassword)
+		if err != nil {
+			respondWithError(w, 403, "Current password is incorrect")
+			return
+		}
+		hashedPassword, err = auth.HashPassword(*params.Password)
+		if err != nil {
+			respondWithError(w, 400, "Something went wrong: hashing password: "+err.Error())
+			return
+		}
 	}
 
-	u, err := cfg.db.UpdateUserInfo(context.Background(), uuParams)
+	handle, err := parseHandleUpdate(params.Handle)
 	if err != nil {
-		respondWithError(w, 401, "Unauthorized: updateInfo: "+err.Error())
+		respondWithError(w, 400, err.Error())
+		return
 	}
 
-	respondWithJSON(w, 200, User{
-		ID:          u.ID,
-		Email:       u.Email,
-		CreatedAt:   u.CreatedAt,
-		UpdatedAt:   u.UpdatedAt,
-		IsChirpyRed: u.IsChirpyRed,
-	},
-	)
+	newEmail := ""
+	if params.Email != nil && *params.Email != u.Email {
+		addr, err := mail.ParseAddress(*params.Email)
+		if err != nil || addr.Address != *params.Email {
+			respondWithError(w, 400, "Email is not valid")
+			return
+		}
+		_, err = cfg.db.GetUserByEmail(context.Background(), addr.Address)
+		if err == nil {
+			respondWithError(w, 409, "Email is already taken")
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 400, "Something went wrong: email: "+err.Error())
+			return
+		}
+		if cfg.mailer == nil {
+			respondWithError(w, 503, "Email changes are not available")
+			return
+		}
+		newEmail = addr.Address
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: begin: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	if hashedPassword != "" {
+		u, err = q.UpdateUserPassword(context.Background(), database.UpdateUserPasswordParams{
+			ID:             userId,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			respondWithError(w, 400, "Something went wrong: updatePassword: "+err.Error())
+			return
+		}
+
+		// Every session ends with the old password, and the caller
+		// continues in a new one.
+		_, err = q.DeleteUserSessions(context.Background(), userId)
+		if err != nil {
+			respondWithError(w, 400, "Something went wrong: delete sessions: "+err.Error())
+			return
+		}
+		sessionId, refreshToken, err = createSession(q, r, userId, deviceLabel(r.UserAgent()))
+		if err != nil {
+			respondWithError(w, 400, "Something went wrong: session: "+err.Error())
+			return
+		}
+	}
+
+	if handle.Valid {
+		u, err = q.UpdateUserHandle(context.Background(), database.UpdateUserHandleParams{
+			Handle: handle,
+			ID:     userId,
+		})
+		if respondIfHandleTaken(w, err) {
+			return
+		}
+		if err != nil {
+			respondWithError(w, 400, "Something went wrong: updateHandle: "+err.Error())
+			return
+		}
+	}
+
+	emailToken := ""
+	if newEmail != "" {
+		emailToken, err = cfg.requestEmailChange(q, userId, newEmail)
+		if err != nil {
+			respondWithError(w, 400, "Something went wrong: emailChange: "+err.Error())
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: commit: "+err.Error())
+		return
+	}
+
+	// The mail goes out after the commit, so a slow mail server does not
+	// hold the transaction open. If it fails, the user asks again.
+	if emailToken != "" {
+		go cfg.sendEmailChange(newEmail, emailToken)
+	}
+
+	accessToken := ""
+	if refreshToken != "" {
+		cfg.tokenVersions.Invalidate(userId)
+		accessToken, err = auth.MakeJWT(userId, sessionId, u.TokenVersion, cfg.jwtKeys, time.Hour)
+		if err != nil {
+			respondWithError(w, 400, "Something went wrong: make jwt: "+err.Error())
+			return
+		}
+	}
+
+	respondWithJSON(w, 200, responseBody{
+		User:         userFromDB(u),
+		PendingEmail: newEmail,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+	})
+}
+
+// requestEmailChange stores a pending change of the user's email address and
+// returns the token that confirms it.
+func (cfg *apiConfig) requestEmailChange(q *database.Queries, userId uuid.UUID, newEmail string) (string, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.CreateEmailChange(context.Background(), database.CreateEmailChangeParams{
+		UserID:    userId,
+		NewEmail:  newEmail,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(emailChangeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (cfg *apiConfig) sendEmailChange(newEmail, token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := cfg.mailer.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new Chirpy email address",
+		Body: "Someone asked to change the email address of a Chirpy account to this address.\n\n" +
+			"To confirm, send this token to POST /api/users/email/confirm within 24 hours:\n\n" +
+			token + "\n\n" +
+			"If this was not you, ignore this message.\n",
+	})
+	if err != nil {
+		log.Printf("Error sending email change confirmation: %s\n", err)
+	}
+}
+
+func (cfg *apiConfig) handlerConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	type requestBody struct {
+		Token string `json:"token"`
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: body")
+		return
+	}
+
+	params := requestBody{}
+	err = json.Unmarshal(dat, &params)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: unmarshal: "+err.Error())
+		return
+	}
+
+	ec, err := cfg.db.GetEmailChangeByToken(context.Background(), auth.HashToken(params.Token))
+	if err != nil {
+		respondWithError(w, 404, "Token is invalid or expired")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: begin: "+err.Error())
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	u, err := q.UpdateUserEmail(context.Background(), database.UpdateUserEmailParams{
+		ID:    ec.UserID,
+		Email: ec.NewEmail,
+	})
+	if isUniqueViolation(err, "users_email_key") {
+		respondWithError(w, 409, "Email is already taken")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: updateEmail: "+err.Error())
+		return
+	}
+
+	err = q.DeleteEmailChange(context.Background(), ec.UserID)
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: emailChange: "+err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 400, "Something went wrong: commit: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, userFromDB(u))
 }
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/stream"
+)
+
+const (
+	wsPingInterval     = 30 * time.Second
+	wsWriteTimeout     = 10 * time.Second
+	wsSendBuffer       = 64
+	wsMaxMessage       = 4 << 10
+	wsMaxSubscriptions = 50
+)
+
+type wsClientMessage struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+	ID    string `json:"id,omitempty"`
+}
+
+type wsServerMessage struct {
+	Type  string          `json:"type"`
+	Topic string          `json:"topic,omitempty"`
+	ID    string          `json:"id,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// wsSession is one websocket client. Only the write loop writes data to the
+// connection, and it closes the connection once done is closed.
+type wsSession struct {
+	cfg    *apiConfig
+	conn   *websocket.Conn
+	userId uuid.UUID
+	token  string
+	send   chan []byte
+
+	done        chan struct{}
+	stopOnce    sync.Once
+	closeCode   websocket.StatusCode
+	closeReason string
+
+	mu   sync.Mutex
+	subs map[string]*stream.Subscription
+}
+
+func (cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Browsers can not set headers on a websocket handshake, so the token
+	// may also come as a query parameter.
+	bar := r.URL.Query().Get("access_token")
+	if _, ok := r.Header["Authorization"]; ok {
+		var err error
+		bar, err = auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, 401, "Unauthorized_bar")
+			return
+		}
+	}
+	if bar == "" {
+		respondWithError(w, 401, "Unauthorized_bar")
+		return
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+	expiresAt, err := auth.JWTExpiresAt(bar, cfg.jwtKeys)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized_user: "+err.Error())
+		return
+	}
+
+	// Cross-origin pages are refused unless listed in WS_ORIGINS.
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		OriginPatterns: cfg.wsOrigins,
+	})
+	if err != nil {
+		return
+	}
+	conn.SetReadLimit(wsMaxMessage)
+
+	s := &wsSession{
+		cfg:    cfg,
+		conn:   conn,
+		userId: userId,
+		token:  bar,
+		send:   make(chan []byte, wsSendBuffer),
+		done:   make(chan struct{}),
+		subs:   map[string]*stream.Subscription{},
+	}
+	cfg.wsSessions.add(s)
+	defer cfg.wsSessions.remove(s)
+	go s.writeLoop(expiresAt)
+
+	s.readLoop()
+	s.stop(websocket.StatusNormalClosure, "")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, sub := range s.subs {
+		delete(s.subs, key)
+		sub.Close()
+	}
+}
+
+func (s *wsSession) readLoop() {
+	for {
+		mt, data, err := s.conn.Read(context.Background())
+		if err != nil {
+			return
+		}
+		if mt != websocket.MessageText {
+			s.stop(websocket.StatusUnsupportedData, "text messages only")
+			return
+		}
+		if !utf8.Valid(data) {
+			s.stop(websocket.StatusInvalidFramePayloadData, "invalid UTF-8")
+			return
+		}
+
+		msg := wsClientMessage{}
+		err = json.Unmarshal(data, &msg)
+		if err != nil {
+			s.queue(wsServerMessage{Type: "error", Error: "Messages must be JSON"})
+			continue
+		}
+
+		switch msg.Type {
+		case "subscribe":
+			s.subscribe(msg)
+		case "unsubscribe":
+			s.unsubscribe(msg)
+		default:
+			s.queue(wsServerMessage{Type: "error", Error: "type must be subscribe or unsubscribe"})
+		}
+	}
+}
+
+func (s *wsSession) writeLoop(expiresAt time.Time) {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	expiry := time.NewTimer(time.Until(expiresAt))
+	defer expiry.Stop()
+
+	for {
+		var err error
+		select {
+		case msg := <-s.send:
+			ctx, cancel := context.WithTimeout(context.Background(), wsWriteTimeout)
+			err = s.conn.Write(ctx, websocket.MessageText, msg)
+			cancel()
+		case <-ping.C:
+			// Ping waits for the pong, which the read loop receives.
+			ctx, cancel := context.WithTimeout(context.Background(), wsWriteTimeout)
+			err = s.conn.Ping(ctx)
+			cancel()
+		case <-expiry.C:
+			s.stop(websocket.StatusPolicyViolation, "token expired")
+		case <-s.done:
+			s.conn.Close(s.closeCode, s.closeReason)
+			return
+		}
+		if err != nil {
+			s.stop(websocket.StatusGoingAway, "")
+		}
+	}
+}
+
+// wsRegistry keeps the open websocket sessions by user, so they can be
+// closed when their access token is revoked.
+type wsRegistry struct {
+	mu     sync.Mutex
+	byUser map[uuid.UUID]map[*wsSession]bool
+}
+
+func newWSRegistry() *wsRegistry {
+	return &wsRegistry{byUser: map[uuid.UUID]map[*wsSession]bool{}}
+}
+
+func (r *wsRegistry) add(s *wsSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.byUser[s.userId] == nil {
+		r.byUser[s.userId] = map[*wsSession]bool{}
+	}
+	r.byUser[s.userId][s] = true
+}
+
+func (r *wsRegistry) remove(s *wsSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.byUser[s.userId], s)
+	if len(r.byUser[s.userId]) == 0 {
+		delete(r.byUser, s.userId)
+	}
+}
+
+// sessions returns the open sessions of a user, or of everyone when userId
+// is null.
+func (r *wsRegistry) sessions(userId uuid.NullUUID) []*wsSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sessions := []*wsSession{}
+	for id, byUser := range r.byUser {
+		if userId.Valid && id != userId.UUID {
+			continue
+		}
+		for s := range byUser {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
+// revalidateWebSockets checks the tokens of open websocket sessions again
+// after token versions or sessions of their user changed, and closes those
+// that were revoked. Other errors leave the session open until its token
+// expires.
+func (cfg *apiConfig) revalidateWebSockets(userId uuid.NullUUID) {
+	for _, s := range cfg.wsSessions.sessions(userId) {
+		_, err := auth.ValidateJWT(s.token, cfg.jwtKeys, cfg.tokenVersions)
+		if errors.Is(err, auth.ErrTokenRevoked) {
+			s.stop(websocket.StatusPolicyViolation, "token revoked")
+		}
+	}
+}
+
+// stop ends the session. Only the first call decides the close code.
+func (s *wsSession) stop(code websocket.StatusCode, reason string) {
+	s.stopOnce.Do(func() {
+		s.closeCode = code
+		s.closeReason = reason
+		close(s.done)
+	})
+}
+
+// queue sends a message without blocking. A client that does not read fast
+// enough to keep its buffer from filling up is disconnected.
+func (s *wsSession) queue(msg wsServerMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	select {
+	case <-s.done:
+	case s.send <- data:
+	default:
+		s.stop(websocket.StatusPolicyViolation, "too slow")
+	}
+}
+
+// topic resolves a subscription request to a hub and the filter for its
+// events.
+func (s *wsSession) topic(msg wsClientMessage) (*stream.Hub, func(stream.Event) bool, error) {
+	switch msg.Topic {
+	case "timeline":
+		// The timeline follows the authors followed when subscribing.
+		ids, err := s.cfg.db.ListFolloweeIDs(context.Background(), s.userId)
+		if err != nil {
+			return nil, nil, errors.New("Error getting timeline")
+		}
+		authors := map[uuid.UUID]bool{s.userId: true}
+		for _, id := range ids {
+			authors[id] = true
+		}
+		return s.cfg.chirpStream, func(e stream.Event) bool {
+			return authors[e.UserID]
+		}, nil
+	case "thread":
+		chirpId, err := uuid.Parse(msg.ID)
+		if err != nil {
+			return nil, nil, errors.New("Chirp not found")
+		}
+		c, err := s.cfg.db.GetChirp(context.Background(), chirpId)
+		if err != nil {
+			return nil, nil, errors.New("Chirp not found")
+		}
+		rootId := c.ID
+		if c.RootID.Valid {
+			rootId = c.RootID.UUID
+		}
+		return s.cfg.chirpStream, func(e stream.Event) bool {
+			return e.ThreadID.Valid && e.ThreadID.UUID == rootId
+		}, nil
+	case "notifications":
+		return s.cfg.notificationStream, func(e stream.Event) bool {
+			return e.UserID == s.userId
+		}, nil
+	}
+
+	return nil, nil, errors.New("topic must be timeline, thread or notifications")
+}
+
+// subscriptionKey is what a subscription is stored under. It is made of the
+// fields the client sent, so unsubscribing needs no lookups that could fail
+// once a thread is gone.
+func subscriptionKey(msg wsClientMessage) string {
+	if msg.Topic == "thread" {
+		return msg.Topic + ":" + msg.ID
+	}
+	return msg.Topic
+}
+
+func (s *wsSession) subscribe(msg wsClientMessage) {
+	key := subscriptionKey(msg)
+	hub, filter, err := s.topic(msg)
+	if err != nil {
+		s.queue(wsServerMessage{Type: "error", Topic: msg.Topic, ID: msg.ID, Error: err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	_, exists := s.subs[key]
+	if !exists && len(s.subs) >= wsMaxSubscriptions {
+		s.mu.Unlock()
+		s.queue(wsServerMessage{Type: "error", Topic: msg.Topic, ID: msg.ID, Error: "Too many subscriptions"})
+		return
+	}
+	if !exists {
+		sub := hub.Subscribe(wsSendBuffer, filter)
+		s.subs[key] = sub
+		go s.forward(key, msg, sub)
+	}
+	s.mu.Unlock()
+
+	s.queue(wsServerMessage{Type: "subscribed", Topic: msg.Topic, ID: msg.ID})
+}
+
+func (s *wsSession) unsubscribe(msg wsClientMessage) {
+	key := subscriptionKey(msg)
+
+	s.mu.Lock()
+	sub, ok := s.subs[key]
+	delete(s.subs, key)
+	s.mu.Unlock()
+	if ok {
+		sub.Close()
+	}
+
+	s.queue(wsServerMessage{Type: "unsubscribed", Topic: msg.Topic, ID: msg.ID})
+}
+
+func (s *wsSession) forward(key string, msg wsClientMessage, sub *stream.Subscription) {
+	for e := range sub.C {
+		s.queue(wsServerMessage{Type: e.Type, Topic: msg.Topic, ID: msg.ID, Data: e.Data})
+	}
+
+	// The channel is closed either by unsubscribing, which removes the
+	// subscription first, or by the hub dropping a subscriber that fell
+	// behind.
+	s.mu.Lock()
+	dropped := s.subs[key] == sub
+	s.mu.Unlock()
+	if dropped {
+		s.stop(websocket.StatusPolicyViolation, "too slow")
+	}
+}
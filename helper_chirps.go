@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/auth"
+	"github.com/vystepanenko/Chirpy/internal/database"
+)
+
+// optionalViewer returns the authenticated user for endpoints that are
+// public but personalise their response when a valid token is present.
+func (cfg *apiConfig) optionalViewer(r *http.Request) uuid.NullUUID {
+	if _, ok := r.Header["Authorization"]; !ok {
+		return uuid.NullUUID{}
+	}
+
+	bar, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	userId, err := auth.ValidateJWT(bar, cfg.jwtKeys, cfg.tokenVersions)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+
+	return uuid.NullUUID{UUID: userId, Valid: true}
+}
+
+func (cfg *apiConfig) chirpsFromDB(viewer uuid.NullUUID, chirps []database.Chirp) ([]Chirpy, error) {
+	result := make([]Chirpy, 0, len(chirps))
+	for _, v := range chirps {
+		result = append(result, chirpFromDB(v))
+	}
+
+	ptrs := make([]*Chirpy, 0, len(result))
+	for i := range result {
+		ptrs = append(ptrs, &result[i])
+	}
+
+	return result, cfg.decorateChirps(viewer, ptrs)
+}
+
+// decorateChirps fills in the embedded and aggregate fields of a page of
+// chirps with a fixed number of queries, however many chirps are on the page.
+func (cfg *apiConfig) decorateChirps(viewer uuid.NullUUID, chirps []*Chirpy) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	embedded, err := cfg.embedReferencedChirps(chirps)
+	if err != nil {
+		return err
+	}
+	chirps = append(chirps, embedded...)
+
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, c := range chirps {
+		ids = append(ids, c.ID)
+	}
+
+	counts, err := cfg.db.CountLikesForChirps(context.Background(), ids)
+	if err != nil {
+		return err
+	}
+	likeCounts := make(map[uuid.UUID]int64, len(counts))
+	for _, v := range counts {
+		likeCounts[v.ChirpID] = v.LikeCount
+	}
+
+	liked := make(map[uuid.UUID]struct{})
+	bookmarked := make(map[uuid.UUID]struct{})
+	if viewer.Valid {
+		likedIds, err := cfg.db.ListLikedChirpIDs(context.Background(), database.ListLikedChirpIDsParams{
+			UserID:   viewer.UUID,
+			ChirpIds: ids,
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range likedIds {
+			liked[id] = struct{}{}
+		}
+
+		bookmarkedIds, err := cfg.db.ListBookmarkedChirpIDs(context.Background(), database.ListBookmarkedChirpIDsParams{
+			UserID:   viewer.UUID,
+			ChirpIds: ids,
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range bookmarkedIds {
+			bookmarked[id] = struct{}{}
+		}
+	}
+
+	attachments, err := cfg.db.ListAttachmentsForChirps(context.Background(), ids)
+	if err != nil {
+		return err
+	}
+	chirpMedia := make(map[uuid.UUID][]ChirpyMedia)
+	for _, a := range attachments {
+		chirpMedia[a.ChirpID.UUID] = append(chirpMedia[a.ChirpID.UUID], cfg.mediaFromDB(a))
+	}
+
+	err = cfg.decoratePolls(viewer, chirps, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range chirps {
+		c.LikeCount = likeCounts[c.ID]
+		_, c.LikedByMe = liked[c.ID]
+		_, c.Bookmarked = bookmarked[c.ID]
+		c.Media = chirpMedia[c.ID]
+	}
+
+	return nil
+}
+
+// embedReferencedChirps attaches the originals of rechirps and quotes. Only
+// one level is embedded, so a quote of a quote shows just the first quote.
+func (cfg *apiConfig) embedReferencedChirps(chirps []*Chirpy) ([]*Chirpy, error) {
+	ids := make([]uuid.UUID, 0)
+	for _, c := range chirps {
+		if c.rechirpOfId.Valid {
+			ids = append(ids, c.rechirpOfId.UUID)
+		}
+		if c.quoteOfId.Valid {
+			ids = append(ids, c.quoteOfId.UUID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	referenced, err := cfg.db.GetChirpsByIDs(context.Background(), ids)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[uuid.UUID]database.Chirp, len(referenced))
+	for _, v := range referenced {
+		byId[v.ID] = v
+	}
+
+	embedded := make([]*Chirpy, 0, len(ids))
+	for _, c := range chirps {
+		if original, ok := byId[c.rechirpOfId.UUID]; ok && c.rechirpOfId.Valid {
+			chirp := chirpFromDB(original)
+			c.RechirpOf = &chirp
+			embedded = append(embedded, c.RechirpOf)
+		}
+		if original, ok := byId[c.quoteOfId.UUID]; ok && c.quoteOfId.Valid {
+			chirp := chirpFromDB(original)
+			c.QuoteOf = &chirp
+			embedded = append(embedded, c.QuoteOf)
+		}
+	}
+
+	return embedded, nil
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
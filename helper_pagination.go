@@ -0,0 +1,193 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/pagination"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+type pageRequest struct {
+	limit    int32
+	cursor   *pagination.Cursor
+	backward bool
+}
+
+func parsePageRequest(r *http.Request) (pageRequest, error) {
+	query := r.URL.Query()
+	page := pageRequest{limit: defaultPageLimit}
+
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return pageRequest{}, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+		page.limit = int32(limit)
+	}
+
+	before := query.Get("before")
+	after := query.Get("after")
+	if before != "" && after != "" {
+		return pageRequest{}, errors.New("Use either before or after, not both")
+	}
+
+	raw := after
+	if before != "" {
+		raw = before
+		page.backward = true
+	}
+	if raw != "" {
+		c, err := pagination.Decode(raw)
+		if err != nil {
+			return pageRequest{}, err
+		}
+		page.cursor = &c
+	}
+
+	return page, nil
+}
+
+// queryLimit asks for one extra row so we know whether another page exists.
+func (p pageRequest) queryLimit() int32 {
+	return p.limit + 1
+}
+
+func (p pageRequest) cursorParams() (sql.NullTime, uuid.NullUUID) {
+	if p.cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+
+	return sql.NullTime{Time: p.cursor.CreatedAt, Valid: true},
+		uuid.NullUUID{UUID: p.cursor.ID, Valid: true}
+}
+
+// paginate trims the look-ahead row, restores display order for backward
+// pages and returns the prev/next cursors for the page.
+func paginate[T any](rows []T, page pageRequest, key func(T) pagination.Cursor) ([]T, string, string) {
+	hasMore := len(rows) > int(page.limit)
+	if hasMore {
+		rows = rows[:page.limit]
+	}
+
+	if page.backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	if len(rows) == 0 {
+		return rows, "", ""
+	}
+
+	prev, next := "", ""
+	first, last := key(rows[0]).Encode(), key(rows[len(rows)-1]).Encode()
+	if page.backward {
+		next = last
+		if hasMore {
+			prev = first
+		}
+	} else {
+		if hasMore {
+			next = last
+		}
+		if page.cursor != nil {
+			prev = first
+		}
+	}
+
+	return rows, prev, next
+}
+
+type offsetPageRequest struct {
+	limit  int32
+	offset int32
+}
+
+// parseOffsetPageRequest reads the same limit/after/before parameters as
+// parsePageRequest, but for listings paged by offset.
+func parseOffsetPageRequest(r *http.Request) (offsetPageRequest, error) {
+	query := r.URL.Query()
+	page := offsetPageRequest{limit: defaultPageLimit}
+
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return offsetPageRequest{}, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+		page.limit = int32(limit)
+	}
+
+	before := query.Get("before")
+	after := query.Get("after")
+	if before != "" && after != "" {
+		return offsetPageRequest{}, errors.New("Use either before or after, not both")
+	}
+
+	if after != "" {
+		offset, err := pagination.DecodeOffset(after)
+		if err != nil {
+			return offsetPageRequest{}, err
+		}
+		page.offset = int32(offset)
+	}
+	if before != "" {
+		offset, err := pagination.DecodeOffset(before)
+		if err != nil {
+			return offsetPageRequest{}, err
+		}
+		page.offset = max(int32(offset)-page.limit, 0)
+	}
+
+	return page, nil
+}
+
+func (p offsetPageRequest) queryLimit() int32 {
+	return p.limit + 1
+}
+
+func paginateOffset[T any](rows []T, page offsetPageRequest) ([]T, string, string) {
+	prev, next := "", ""
+	if len(rows) > int(page.limit) {
+		rows = rows[:page.limit]
+		next = pagination.EncodeOffset(int(page.offset + page.limit))
+	}
+	if page.offset > 0 {
+		prev = pagination.EncodeOffset(int(page.offset))
+	}
+
+	return rows, prev, next
+}
+
+func setPageHeaders(w http.ResponseWriter, r *http.Request, prev, next string) {
+	links := make([]string, 0, 2)
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+		links = append(links, `<`+pageURL(r, "after", next)+`>; rel="next"`)
+	}
+	if prev != "" {
+		w.Header().Set("X-Prev-Cursor", prev)
+		links = append(links, `<`+pageURL(r, "before", prev)+`>; rel="prev"`)
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+func pageURL(r *http.Request, param, cursor string) string {
+	query := r.URL.Query()
+	query.Del("before")
+	query.Del("after")
+	query.Set(param, cursor)
+
+	return r.URL.Path + "?" + query.Encode()
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/moderation"
+	"github.com/vystepanenko/Chirpy/internal/notifications"
+)
+
+// chirpRequest is what a new chirp is made from, whether it is posted right
+// away or saved as a draft first.
+type chirpRequest struct {
+	Body      string       `json:"body"`
+	InReplyTo string       `json:"in_reply_to,omitempty"`
+	RechirpOf string       `json:"rechirp_of,omitempty"`
+	QuoteOf   string       `json:"quote_of,omitempty"`
+	MediaIDs  []uuid.UUID  `json:"media_ids,omitempty"`
+	Poll      *pollRequest `json:"poll,omitempty"`
+}
+
+// chirpError is a chirp that can not be created, with the status to answer
+// the request with.
+type chirpError struct {
+	status int
+	msg    string
+}
+
+func (e *chirpError) Error() string {
+	return e.msg
+}
+
+// newChirp is a created chirp together with what publishChirp needs to know
+// about how it was created.
+type newChirp struct {
+	chirp     database.Chirp
+	moderated moderation.Result
+	// Authors of the chirps this one replies to, rechirps or quotes.
+	parentAuthor   uuid.UUID
+	originalAuthor uuid.UUID
+}
+
+// createChirp validates a chirp request and saves the chirp with its media
+// and poll. It is run in a transaction, and publishChirp must be called once
+// that has committed.
+func (cfg *apiConfig) createChirp(q *database.Queries, userId uuid.UUID, params chirpRequest) (newChirp, error) {
+	moderated, err := cfg.validateChirpBody(params.Body)
+	if err != nil {
+		return newChirp{}, &chirpError{400, err.Error()}
+	}
+	n := newChirp{moderated: moderated}
+	chirpyParams := database.CreateChirpsParams{
+		Body:   moderated.Body,
+		UserID: userId,
+	}
+	if params.InReplyTo != "" {
+		parentId, err := uuid.Parse(params.InReplyTo)
+		if err != nil {
+			return newChirp{}, &chirpError{400, "in_reply_to must be a chirp id"}
+		}
+		parent, err := q.GetChirp(context.Background(), parentId)
+		if err != nil {
+			return newChirp{}, &chirpError{404, "Chirp to reply to not found"}
+		}
+
+		// Replies to a reply join the thread of the original chirp.
+		rootId := parent.ID
+		if parent.RootID.Valid {
+			rootId = parent.RootID.UUID
+		}
+		chirpyParams.ParentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		chirpyParams.RootID = uuid.NullUUID{UUID: rootId, Valid: true}
+		n.parentAuthor = parent.UserID
+	}
+
+	if len(params.MediaIDs) > maxChirpMedia {
+		return newChirp{}, &chirpError{400, "A chirp can have at most 4 images"}
+	}
+	if params.RechirpOf != "" {
+		if params.Body != "" || params.InReplyTo != "" || params.QuoteOf != "" || len(params.MediaIDs) > 0 || params.Poll != nil {
+			return newChirp{}, &chirpError{400, "A rechirp can not have a body, a reply, a quote, media or a poll"}
+		}
+		original, err := referencedChirp(q, params.RechirpOf)
+		if err != nil {
+			return newChirp{}, &chirpError{404, "Chirp to rechirp not found"}
+		}
+		chirpyParams.RechirpOfID = uuid.NullUUID{UUID: original.ID, Valid: true}
+		n.originalAuthor = original.UserID
+	}
+	if params.QuoteOf != "" {
+		if params.Body == "" {
+			return newChirp{}, &chirpError{400, "A quote needs a body"}
+		}
+		original, err := referencedChirp(q, params.QuoteOf)
+		if err != nil {
+			return newChirp{}, &chirpError{404, "Chirp to quote not found"}
+		}
+		chirpyParams.QuoteOfID = uuid.NullUUID{UUID: original.ID, Valid: true}
+		chirpyParams.IsQuote = true
+		n.originalAuthor = original.UserID
+	}
+
+	var pollOptions []string
+	var pollDuration time.Duration
+	if params.Poll != nil {
+		pollOptions, pollDuration, err = cfg.validatePoll(*params.Poll)
+		if err != nil {
+			return newChirp{}, &chirpError{400, err.Error()}
+		}
+	}
+
+	n.chirp, err = q.CreateChirps(context.Background(), chirpyParams)
+	if isUniqueViolation(err, "chirps_user_id_rechirp_of_id_idx") {
+		return newChirp{}, &chirpError{409, "You already rechirped this chirp"}
+	}
+	if err != nil {
+		return newChirp{}, err
+	}
+	if len(params.MediaIDs) > 0 {
+		attached, err := q.AttachToChirp(context.Background(), database.AttachToChirpParams{
+			ChirpID: n.chirp.ID,
+			Ids:     params.MediaIDs,
+			UserID:  userId,
+		})
+		if err != nil {
+			return newChirp{}, fmt.Errorf("attach media: %w", err)
+		}
+		// Duplicate ids, other users' uploads and media already used by
+		// another chirp are not attached.
+		if len(attached) != len(params.MediaIDs) {
+			return newChirp{}, &chirpError{400, "Media not found"}
+		}
+	}
+	if params.Poll != nil {
+		err = createPoll(q, n.chirp.ID, pollOptions, pollDuration)
+		if err != nil {
+			return newChirp{}, fmt.Errorf("poll: %w", err)
+		}
+	}
+
+	return n, nil
+}
+
+// publishChirp runs everything that follows a new chirp: tags, mentions,
+// timelines, moderation flags, stream events and notifications. Failures
+// are logged rather than undoing the chirp.
+func (cfg *apiConfig) publishChirp(n newChirp) {
+	c := n.chirp
+
+	err := tagChirp(cfg.db, c.ID, c.Body)
+	if err != nil {
+		log.Printf("Error tagging chirp %s: %s\n", c.ID, err)
+	}
+	mentioned, err := mentionChirp(cfg.db, c.ID, c.Body)
+	if err != nil {
+		log.Printf("Error saving mentions of chirp %s: %s\n", c.ID, err)
+	}
+	cfg.fanOutChirp(c.ID)
+	cfg.recordChirpFlag(c.ID, n.moderated)
+	cfg.recordChirpEvent(chirpEventCreated, c)
+
+	switch {
+	case c.ParentID.Valid:
+		cfg.notify(notifications.TypeReply, n.parentAuthor, c.UserID, c.ID)
+	case c.RechirpOfID.Valid:
+		cfg.notify(notifications.TypeRechirp, n.originalAuthor, c.UserID, c.ID)
+	}
+	if c.QuoteOfID.Valid {
+		cfg.notify(notifications.TypeQuote, n.originalAuthor, c.UserID, c.ID)
+	}
+	for _, id := range mentioned {
+		// A reply already notifies the parent author.
+		if c.ParentID.Valid && id == n.parentAuthor {
+			continue
+		}
+		cfg.notify(notifications.TypeMention, id, c.UserID, c.ID)
+	}
+}
@@ -56,3 +56,21 @@ func TestCheckPasswordHash(t *testing.T) {
 		})
 	}
 }
+
+func TestHashToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := HashToken(token)
+	if hash == token || len(hash) != 64 {
+		t.Errorf("HashToken(%q) = %q", token, hash)
+	}
+	if HashToken(token) != hash {
+		t.Error("HashToken is not deterministic")
+	}
+	if HashToken(token+"0") == hash {
+		t.Error("different tokens hash the same")
+	}
+}
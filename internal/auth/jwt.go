@@ -1,9 +1,9 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
@@ -11,45 +11,93 @@ import (
 	"github.com/google/uuid"
 )
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject:   userID.String(),
+type accessClaims struct {
+	jwt.RegisteredClaims
+	// Version is the token version of the user when the token was issued.
+	Version int32 `json:"ver"`
+	// Session is the session the token was issued in.
+	Session string `json:"sid"`
+}
+
+// MakeJWT signs an access token for userID in the session sessionID with the
+// current key of keys. version is the user's current token version.
+func MakeJWT(userID, sessionID uuid.UUID, version int32, keys *KeyManager, expiresIn time.Duration) (string, error) {
+	claims := accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		Version: version,
+		Session: sessionID.String(),
 	}
 
-	token := jwt.NewWithClaims(
-		jwt.SigningMethodHS256,
-		claims,
-	)
+	return keys.sign(claims)
+}
 
-	key := os.Getenv("SECRET_KEY")
-	if key == "" {
-		return "", errors.New("Can not find secret key")
+// ValidateJWT checks the signature and expiry of an access token, that it
+// carries the current token version of its user and that its session has
+// not ended.
+func ValidateJWT(tokenString string, keys *KeyManager, versions TokenVersions) (uuid.UUID, error) {
+	claims := &accessClaims{}
+	_, err := keys.parse(tokenString, claims)
+	if err != nil {
+		return uuid.UUID{}, err
 	}
 
-	return token.SignedString([]byte(key))
-}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		&jwt.RegisteredClaims{Issuer: "chirpy"},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(tokenSecret), nil
-		},
-	)
+	version, err := versions.TokenVersion(context.Background(), userID)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
+	if version != claims.Version {
+		return uuid.UUID{}, ErrTokenRevoked
+	}
 
-	claimSubject, err := token.Claims.GetSubject()
+	// Tokens from before sessions were part of them have no sid.
+	sessionID, err := uuid.Parse(claims.Session)
+	if err != nil {
+		return uuid.UUID{}, ErrTokenRevoked
+	}
+	// iat is cut to whole seconds, and the session started before the token
+	// was issued.
+	issuedAt := time.Time{}
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time.Add(time.Second)
+	}
+	active, err := versions.SessionActive(context.Background(), userID, sessionID, issuedAt)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
+	if !active {
+		return uuid.UUID{}, ErrTokenRevoked
+	}
+
+	return userID, nil
+}
+
+// JWTExpiresAt returns when a token accepted by ValidateJWT stops being
+// valid.
+func JWTExpiresAt(tokenString string, keys *KeyManager) (time.Time, error) {
+	token, err := keys.parse(tokenString, &jwt.RegisteredClaims{})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	expiresAt, err := token.Claims.GetExpirationTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if expiresAt == nil {
+		return time.Time{}, errors.New("Token has no expiry")
+	}
 
-	return uuid.Parse(claimSubject)
+	return expiresAt.Time, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
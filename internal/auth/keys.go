@@ -0,0 +1,417 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/vystepanenko/Chirpy/internal/database"
+)
+
+type Algorithm string
+
+const (
+	AlgRS256 Algorithm = "RS256"
+	AlgEdDSA Algorithm = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+// keyReloadInterval limits how often a token with an unknown kid makes the
+// manager read the store again.
+const keyReloadInterval = 10 * time.Second
+
+var ErrUnknownKey = errors.New("Token is signed with an unknown key")
+
+// KeyStore keeps signing keys where every server instance can see them.
+type KeyStore interface {
+	ListSigningKeys(ctx context.Context) ([]database.SigningKey, error)
+	CreateSigningKey(ctx context.Context, arg database.CreateSigningKeyParams) (database.SigningKey, error)
+	DeleteSigningKey(ctx context.Context, id string) error
+}
+
+type KeyManagerConfig struct {
+	// Algorithm is used for newly generated keys. Keys of the other
+	// algorithm keep verifying until they are retired.
+	Algorithm Algorithm
+	// RotationInterval is how long each key signs tokens.
+	RotationInterval time.Duration
+	// PublishAhead is how long a new key is in the JWKS before it signs
+	// anything, so verifiers that cache the set know it in time.
+	PublishAhead time.Duration
+	// VerifyFor is how long a key stays in the JWKS after it stopped
+	// signing. It must be at least the lifetime of an access token.
+	VerifyFor time.Duration
+	// EncryptionKey protects the private keys in the store.
+	EncryptionKey []byte
+}
+
+type signingKey struct {
+	id        string
+	alg       Algorithm
+	private   crypto.Signer
+	createdAt time.Time
+}
+
+func (k signingKey) method() jwt.SigningMethod {
+	if k.alg == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// KeyManager signs access tokens with asymmetric keys identified by kid and
+// rotates them on a schedule. Keys live in a KeyStore, so any instance can
+// verify tokens signed by another.
+type KeyManager struct {
+	store KeyStore
+	cfg   KeyManagerConfig
+	box   *SecretBox
+	now   func() time.Time
+
+	mu   sync.RWMutex
+	keys []signingKey // oldest first
+
+	reloadMu   sync.Mutex
+	reloadedAt time.Time
+}
+
+func NewKeyManager(store KeyStore, cfg KeyManagerConfig) (*KeyManager, error) {
+	if cfg.Algorithm != AlgRS256 && cfg.Algorithm != AlgEdDSA {
+		return nil, fmt.Errorf("Unsupported signing algorithm %q", cfg.Algorithm)
+	}
+	if len(cfg.EncryptionKey) == 0 {
+		return nil, errors.New("An encryption key for signing keys is required")
+	}
+	if cfg.RotationInterval <= cfg.PublishAhead {
+		return nil, errors.New("Rotation interval must be longer than the publish ahead time")
+	}
+
+	box, err := NewSecretBox(cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyManager{store: store, cfg: cfg, box: box, now: time.Now}, nil
+}
+
+// Refresh loads the keys from the store, generates the next key when it is
+// due and deletes keys that can no longer have valid tokens.
+//
+// Instances refreshing at the same moment may both generate a key. That is
+// harmless: both are published and the newer one signs.
+func (m *KeyManager) Refresh(ctx context.Context) error {
+	keys, err := m.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := m.now().UTC()
+	if len(keys) == 0 || !now.Before(keys[len(keys)-1].createdAt.Add(m.cfg.RotationInterval-m.cfg.PublishAhead)) {
+		k, err := m.generate(ctx, now)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+
+	// A key signs until the key after it is ready, and verifies for
+	// VerifyFor after that.
+	signing := m.signingIndex(keys, now)
+	kept := make([]signingKey, 0, len(keys))
+	for i, k := range keys {
+		if i < signing && !now.Before(keys[i+1].createdAt.Add(m.cfg.PublishAhead+m.cfg.VerifyFor)) {
+			err := m.store.DeleteSigningKey(ctx, k.id)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		kept = append(kept, k)
+	}
+
+	m.mu.Lock()
+	m.keys = kept
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Run refreshes the keys every interval, which must be well below
+// PublishAhead.
+func (m *KeyManager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := m.Refresh(ctx)
+			if err != nil {
+				log.Printf("Error refreshing signing keys: %s\n", err)
+			}
+		}
+	}
+}
+
+// signingIndex picks the newest key that has been published for long
+// enough, or the oldest key if none has.
+func (m *KeyManager) signingIndex(keys []signingKey, now time.Time) int {
+	for i := len(keys) - 1; i >= 0; i-- {
+		if !now.Before(keys[i].createdAt.Add(m.cfg.PublishAhead)) {
+			return i
+		}
+	}
+	return 0
+}
+
+func (m *KeyManager) load(ctx context.Context) ([]signingKey, error) {
+	rows, err := m.store.ListSigningKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]signingKey, 0, len(rows))
+	for _, row := range rows {
+		k, err := m.decode(row)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", row.ID, err)
+		}
+		keys = append(keys, k)
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].createdAt.Before(keys[j].createdAt) })
+
+	return keys, nil
+}
+
+func (m *KeyManager) generate(ctx context.Context, now time.Time) (signingKey, error) {
+	var private crypto.Signer
+	var err error
+	if m.cfg.Algorithm == AlgEdDSA {
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	} else {
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	}
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	k := signingKey{
+		id:        thumbprint(publicJWK(private.Public())),
+		alg:       m.cfg.Algorithm,
+		private:   private,
+		createdAt: now,
+	}
+
+	sealed, err := m.box.Seal(der, []byte(k.id))
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	_, err = m.store.CreateSigningKey(ctx, database.CreateSigningKeyParams{
+		ID:         k.id,
+		Algorithm:  string(k.alg),
+		PrivateKey: sealed,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	return k, nil
+}
+
+func (m *KeyManager) decode(row database.SigningKey) (signingKey, error) {
+	der, err := m.box.Open(row.PrivateKey, []byte(row.ID))
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	k := signingKey{id: row.ID, alg: Algorithm(row.Algorithm), createdAt: row.CreatedAt}
+	switch p := parsed.(type) {
+	case *rsa.PrivateKey:
+		k.private = p
+	case ed25519.PrivateKey:
+		k.private = p
+	default:
+		return signingKey{}, fmt.Errorf("Unsupported private key %T", parsed)
+	}
+	if k.alg != AlgRS256 && k.alg != AlgEdDSA {
+		return signingKey{}, fmt.Errorf("Unsupported signing algorithm %q", k.alg)
+	}
+
+	return k, nil
+}
+
+func (m *KeyManager) sign(claims jwt.Claims) (string, error) {
+	m.mu.RLock()
+	keys := m.keys
+	m.mu.RUnlock()
+	if len(keys) == 0 {
+		return "", errors.New("No signing key is loaded")
+	}
+
+	k := keys[m.signingIndex(keys, m.now().UTC())]
+	token := jwt.NewWithClaims(k.method(), claims)
+	token.Header["kid"] = k.id
+
+	return token.SignedString(k.private)
+}
+
+func (m *KeyManager) parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		m.keyfunc,
+		jwt.WithValidMethods([]string{string(AlgRS256), string(AlgEdDSA)}),
+		jwt.WithIssuer("chirpy"),
+	)
+}
+
+// keyfunc finds the key a token was signed with. A kid it does not know may
+// belong to a key another instance generated since the last refresh, so the
+// keys are read from the store again, at most once per keyReloadInterval.
+func (m *KeyManager) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	k, ok := m.find(kid)
+	if !ok && kid != "" && m.reload() {
+		k, ok = m.find(kid)
+	}
+	if !ok || token.Method.Alg() != string(k.alg) {
+		return nil, ErrUnknownKey
+	}
+	return k.private.Public(), nil
+}
+
+func (m *KeyManager) find(kid string) (signingKey, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, k := range m.keys {
+		if k.id == kid {
+			return k, true
+		}
+	}
+	return signingKey{}, false
+}
+
+// reload reads the keys from the store without generating or deleting any,
+// and reports whether it did.
+func (m *KeyManager) reload() bool {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	now := m.now()
+	if now.Before(m.reloadedAt.Add(keyReloadInterval)) {
+		return false
+	}
+	m.reloadedAt = now
+
+	keys, err := m.load(context.Background())
+	if err != nil {
+		log.Printf("Error reloading signing keys: %s\n", err)
+		return false
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
+
+	return true
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every key that signs or may have signed
+// a token that is still valid, and of the key that signs next.
+func (m *KeyManager) JWKS() JWKSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(m.keys))}
+	for _, k := range m.keys {
+		jwk := publicJWK(k.private.Public())
+		jwk.Kid = k.id
+		jwk.Use = "sig"
+		jwk.Alg = string(k.alg)
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func publicJWK(public crypto.PublicKey) JWK {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch p := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", N: b64(p.N.Bytes()), E: b64(big.NewInt(int64(p.E)).Bytes())}
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: b64(p)}
+	}
+	return JWK{}
+}
+
+// thumbprint is the RFC 7638 SHA-256 thumbprint of a public key, used as
+// its kid.
+func thumbprint(jwk JWK) string {
+	// The required members in lexicographic order, without whitespace.
+	var members []byte
+	if jwk.Kty == "RSA" {
+		members, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N})
+	} else {
+		members, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X})
+	}
+
+	sum := sha256.Sum256(members)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
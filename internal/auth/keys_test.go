@@ -0,0 +1,367 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/database"
+)
+
+type memKeyStore struct {
+	keys []database.SigningKey
+}
+
+func (s *memKeyStore) ListSigningKeys(ctx context.Context) ([]database.SigningKey, error) {
+	return append([]database.SigningKey(nil), s.keys...), nil
+}
+
+func (s *memKeyStore) CreateSigningKey(ctx context.Context, arg database.CreateSigningKeyParams) (database.SigningKey, error) {
+	k := database.SigningKey(arg)
+	s.keys = append(s.keys, k)
+	return k, nil
+}
+
+func (s *memKeyStore) DeleteSigningKey(ctx context.Context, id string) error {
+	for i, k := range s.keys {
+		if k.ID == id {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// zeroVersions leaves every user at the initial token version, with every
+// session still open.
+type zeroVersions struct{}
+
+func (zeroVersions) TokenVersion(ctx context.Context, userID uuid.UUID) (int32, error) {
+	return 0, nil
+}
+
+func (zeroVersions) SessionActive(ctx context.Context, userID, sessionID uuid.UUID, issuedAt time.Time) (bool, error) {
+	return true, nil
+}
+
+func newTestKeyManager(t *testing.T, store KeyStore, alg Algorithm, now *time.Time) *KeyManager {
+	t.Helper()
+	m, err := NewKeyManager(store, KeyManagerConfig{
+		Algorithm:        alg,
+		RotationInterval: 24 * time.Hour,
+		PublishAhead:     15 * time.Minute,
+		VerifyFor:        2 * time.Hour,
+		EncryptionKey:    []byte("test secret"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return *now }
+	return m
+}
+
+func TestMakeAndValidateJWT(t *testing.T) {
+	for _, alg := range []Algorithm{AlgRS256, AlgEdDSA} {
+		t.Run(string(alg), func(t *testing.T) {
+			now := time.Now()
+			m := newTestKeyManager(t, &memKeyStore{}, alg, &now)
+			if err := m.Refresh(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			userID := uuid.New()
+			token, err := MakeJWT(userID, uuid.New(), 0, m, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ValidateJWT(token, m, zeroVersions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != userID {
+				t.Errorf("ValidateJWT() = %v, want %v", got, userID)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header["alg"] != string(alg) || parsed.Header["kid"] != m.JWKS().Keys[0].Kid {
+				t.Errorf("header = %v", parsed.Header)
+			}
+		})
+	}
+}
+
+func TestValidateJWTRejects(t *testing.T) {
+	now := time.Now()
+	m := newTestKeyManager(t, &memKeyStore{}, AlgEdDSA, &now)
+	if err := m.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	kid := m.JWKS().Keys[0].Kid
+
+	expired, err := MakeJWT(uuid.New(), uuid.New(), 0, m, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An HS256 token "signed" with the public key must not be accepted.
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Issuer: "chirpy", Subject: uuid.NewString()})
+	hs.Header["kid"] = kid
+	hsToken, err := hs.SignedString([]byte(m.JWKS().Keys[0].X))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, foreignKey, _ := ed25519.GenerateKey(nil)
+	foreign := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.RegisteredClaims{Issuer: "chirpy", Subject: uuid.NewString()})
+	foreign.Header["kid"] = kid
+	foreignToken, err := foreign.SignedString(foreignKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := newTestKeyManager(t, &memKeyStore{}, AlgEdDSA, &now)
+	if err := other.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	otherToken, err := MakeJWT(uuid.New(), uuid.New(), 0, other, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"expired":     expired,
+		"hs256":       hsToken,
+		"wrong key":   foreignToken,
+		"unknown kid": otherToken,
+		"not a token": "not-a-token",
+		"empty":       "",
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ValidateJWT(token, m, zeroVersions{}); err == nil {
+				t.Error("ValidateJWT() accepted the token")
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	store := &memKeyStore{}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := newTestKeyManager(t, store, AlgEdDSA, &now)
+
+	if err := m.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	first := m.JWKS().Keys[0].Kid
+	oldToken, err := MakeJWT(uuid.New(), uuid.New(), 0, m, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The next key is published ahead of its use.
+	now = now.Add(24*time.Hour - 15*time.Minute)
+	if err := m.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.JWKS().Keys); n != 2 {
+		t.Fatalf("%d keys published, want 2", n)
+	}
+	if kidOf(t, mustMakeJWT(t, m)) != first {
+		t.Error("new key signs before it has been published for long enough")
+	}
+
+	// Once it is ready it signs, and another instance sharing the store
+	// verifies its tokens.
+	now = now.Add(15 * time.Minute)
+	token := mustMakeJWT(t, m)
+	if kidOf(t, token) == first {
+		t.Error("old key still signs after rotation")
+	}
+	other := newTestKeyManager(t, store, AlgEdDSA, &now)
+	if err := other.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateJWT(token, other, zeroVersions{}); err != nil {
+		t.Errorf("other instance: %v", err)
+	}
+
+	// The old key is dropped once its tokens have expired.
+	now = now.Add(2*time.Hour - time.Minute)
+	if err := m.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.JWKS().Keys); n != 2 {
+		t.Fatalf("%d keys published before the old key retired, want 2", n)
+	}
+	now = now.Add(time.Minute)
+	if err := m.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.JWKS().Keys); n != 1 || len(store.keys) != 1 {
+		t.Fatalf("%d keys published and %d stored after the old key retired, want 1", n, len(store.keys))
+	}
+	if _, err := ValidateJWT(oldToken, m, zeroVersions{}); err == nil {
+		t.Error("token of a retired key accepted")
+	}
+}
+
+// countingKeyStore counts how often the keys are listed.
+type countingKeyStore struct {
+	memKeyStore
+	lists int
+}
+
+func (s *countingKeyStore) ListSigningKeys(ctx context.Context) ([]database.SigningKey, error) {
+	s.lists++
+	return s.memKeyStore.ListSigningKeys(ctx)
+}
+
+func TestUnknownKidReloadsKeys(t *testing.T) {
+	now := time.Now()
+	store := &countingKeyStore{}
+	signer := newTestKeyManager(t, store, AlgEdDSA, &now)
+	verifier := newTestKeyManager(t, store, AlgEdDSA, &now)
+
+	// The signer generates its key after the verifier last loaded the keys.
+	if err := signer.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateJWT(mustMakeJWT(t, signer), verifier, zeroVersions{}); err != nil {
+		t.Fatalf("ValidateJWT() with a key generated elsewhere: %v", err)
+	}
+
+	other := newTestKeyManager(t, &memKeyStore{}, AlgEdDSA, &now)
+	if err := other.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	foreign := mustMakeJWT(t, other)
+	lists := store.lists
+	for range 3 {
+		if _, err := ValidateJWT(foreign, verifier, zeroVersions{}); err == nil {
+			t.Fatal("ValidateJWT() accepted a token of another key store")
+		}
+	}
+	if store.lists != lists {
+		t.Errorf("unknown kids read the store %d times within the reload interval", store.lists-lists)
+	}
+
+	now = now.Add(keyReloadInterval)
+	ValidateJWT(foreign, verifier, zeroVersions{})
+	if store.lists != lists+1 {
+		t.Errorf("store read %d times after the reload interval, want 1", store.lists-lists)
+	}
+}
+
+func TestKeysAreEncryptedAtRest(t *testing.T) {
+	store := &memKeyStore{}
+	now := time.Now()
+	m := newTestKeyManager(t, store, AlgEdDSA, &now)
+	if err := m.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	wrong, err := NewKeyManager(store, KeyManagerConfig{
+		Algorithm:        AlgEdDSA,
+		RotationInterval: 24 * time.Hour,
+		PublishAhead:     15 * time.Minute,
+		VerifyFor:        2 * time.Hour,
+		EncryptionKey:    []byte("another secret"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wrong.Refresh(context.Background()); err == nil {
+		t.Error("keys decrypted with the wrong encryption key")
+	}
+}
+
+func TestJWKSVerifiesTokens(t *testing.T) {
+	for _, alg := range []Algorithm{AlgRS256, AlgEdDSA} {
+		t.Run(string(alg), func(t *testing.T) {
+			now := time.Now()
+			m := newTestKeyManager(t, &memKeyStore{}, alg, &now)
+			if err := m.Refresh(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			token := mustMakeJWT(t, m)
+
+			// Verify the way a third party would, from the published set only.
+			jwk := m.JWKS().Keys[0]
+			if jwk.Kid != thumbprint(jwk) {
+				t.Errorf("kid %q is not the key thumbprint", jwk.Kid)
+			}
+			_, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+				return publicKeyFromJWK(t, jwk), nil
+			}, jwt.WithValidMethods([]string{jwk.Alg}))
+			if err != nil {
+				t.Errorf("token does not verify with the JWKS: %v", err)
+			}
+		})
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	// RFC 7638, section 3.1.
+	jwk := JWK{
+		Kty: "RSA",
+		E:   "AQAB",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMs" +
+			"tn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n9" +
+			"1CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	}
+	if got, want := thumbprint(jwk), "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Errorf("thumbprint() = %q, want %q", got, want)
+	}
+}
+
+func mustMakeJWT(t *testing.T, m *KeyManager) string {
+	t.Helper()
+	token, err := MakeJWT(uuid.New(), uuid.New(), 0, m, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func kidOf(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func publicKeyFromJWK(t *testing.T, jwk JWK) interface{} {
+	t.Helper()
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(decode(jwk.N)), E: int(new(big.Int).SetBytes(decode(jwk.E)).Int64())}
+	case "OKP":
+		return ed25519.PublicKey(decode(jwk.X))
+	}
+	t.Fatalf("unexpected key type %q", jwk.Kty)
+	return nil
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// SecretBox encrypts secrets that are kept in the database, such as signing
+// keys and TOTP secrets, with AES-GCM under a key derived from a server
+// secret. The additional data binds a ciphertext to what it belongs to, so
+// it can not be moved to another row.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) == 0 {
+		return nil, errors.New("An encryption key is required")
+	}
+
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal encrypts plaintext. The nonce is prepended to the result.
+func (b *SecretBox) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func (b *SecretBox) Open(ciphertext, additionalData []byte) ([]byte, error) {
+	n := b.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, errors.New("Ciphertext is truncated")
+	}
+
+	return b.aead.Open(nil, ciphertext[:n], ciphertext[n:], additionalData)
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSecretBox(t *testing.T) {
+	box, err := NewSecretBox([]byte("test secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := box.Seal([]byte("plaintext"), []byte("row 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("plaintext")) {
+		t.Error("Seal() left the plaintext readable")
+	}
+
+	opened, err := box.Open(sealed, []byte("row 1"))
+	if err != nil || string(opened) != "plaintext" {
+		t.Errorf("Open() = %q, %v", opened, err)
+	}
+	if _, err := box.Open(sealed, []byte("row 2")); err == nil {
+		t.Error("Open() accepted the ciphertext of another row")
+	}
+	if _, err := box.Open(sealed[:5], []byte("row 1")); err == nil {
+		t.Error("Open() accepted a truncated ciphertext")
+	}
+
+	other, err := NewSecretBox([]byte("another secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open(sealed, []byte("row 1")); err == nil {
+		t.Error("Open() accepted the ciphertext of another key")
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxCachedVersions = 100000
+
+var ErrTokenRevoked = errors.New("Token has been revoked")
+
+// TokenVersions reports the current token version of a user. Access tokens
+// carrying any other version are rejected, so bumping it revokes every
+// token issued before. It returns ErrTokenRevoked for users that can not
+// hold valid tokens at all, such as banned ones.
+//
+// Access tokens also name the session they were issued in, and stop being
+// valid when that session ends.
+type TokenVersions interface {
+	TokenVersion(ctx context.Context, userID uuid.UUID) (int32, error)
+	// SessionActive reports whether a session of the user still exists.
+	// Sessions started after issuedAt can not have issued the token, so
+	// only a list read before then is looked at again.
+	SessionActive(ctx context.Context, userID, sessionID uuid.UUID, issuedAt time.Time) (bool, error)
+}
+
+// TokenVersionStore is where token versions and sessions are kept. It finds
+// no token version for users without valid tokens.
+type TokenVersionStore interface {
+	GetUserTokenVersion(ctx context.Context, id uuid.UUID) (int32, error)
+	ListSessionIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+}
+
+type versionEntry struct {
+	version   int32
+	revoked   bool
+	sessions  map[uuid.UUID]bool
+	fetchedAt time.Time
+	expiresAt time.Time
+}
+
+// VersionCache keeps token versions and sessions from a TokenVersionStore in
+// memory, so validating a token does not query the store on every request.
+// Whoever changes a version or ends a session must call Invalidate on every
+// instance; ttl only bounds how long a missed invalidation goes unnoticed.
+type VersionCache struct {
+	store TokenVersionStore
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]versionEntry
+	// generation changes on every invalidation, so a lookup that raced
+	// with one does not cache what it read.
+	generation uint64
+
+	onInvalidate []func(userID uuid.NullUUID)
+}
+
+func NewVersionCache(store TokenVersionStore, ttl time.Duration) *VersionCache {
+	return &VersionCache{
+		store:   store,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[uuid.UUID]versionEntry{},
+	}
+}
+
+// OnInvalidate registers a callback run after each invalidation, with a null
+// user id when every entry was dropped. It must be called before the cache
+// is used.
+func (c *VersionCache) OnInvalidate(fn func(userID uuid.NullUUID)) {
+	c.onInvalidate = append(c.onInvalidate, fn)
+}
+
+func (c *VersionCache) TokenVersion(ctx context.Context, userID uuid.UUID) (int32, error) {
+	e, err := c.entry(ctx, userID, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	if e.revoked {
+		return 0, ErrTokenRevoked
+	}
+	return e.version, nil
+}
+
+func (c *VersionCache) SessionActive(ctx context.Context, userID, sessionID uuid.UUID, issuedAt time.Time) (bool, error) {
+	e, err := c.entry(ctx, userID, time.Time{})
+	if err != nil {
+		return false, err
+	}
+	if !e.sessions[sessionID] && e.fetchedAt.Before(issuedAt) {
+		e, err = c.entry(ctx, userID, issuedAt)
+		if err != nil {
+			return false, err
+		}
+	}
+	return !e.revoked && e.sessions[sessionID], nil
+}
+
+// entry returns the cached entry of a user, reading it from the store when
+// it has expired or was fetched before notBefore.
+func (c *VersionCache) entry(ctx context.Context, userID uuid.UUID, notBefore time.Time) (versionEntry, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	e, ok := c.entries[userID]
+	generation := c.generation
+	c.mu.Unlock()
+
+	if ok && now.Before(e.expiresAt) && !e.fetchedAt.Before(notBefore) {
+		return e, nil
+	}
+
+	e = versionEntry{fetchedAt: now, expiresAt: now.Add(c.ttl)}
+	version, err := c.store.GetUserTokenVersion(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return versionEntry{}, err
+	}
+	e.version, e.revoked = version, err != nil
+	if !e.revoked {
+		ids, err := c.store.ListSessionIDs(ctx, userID)
+		if err != nil {
+			return versionEntry{}, err
+		}
+		e.sessions = make(map[uuid.UUID]bool, len(ids))
+		for _, id := range ids {
+			e.sessions[id] = true
+		}
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		if len(c.entries) >= maxCachedVersions {
+			clear(c.entries)
+		}
+		c.entries[userID] = e
+	}
+	c.mu.Unlock()
+
+	return e, nil
+}
+
+// Invalidate drops the cached version and sessions of a user.
+func (c *VersionCache) Invalidate(userID uuid.UUID) {
+	c.mu.Lock()
+	delete(c.entries, userID)
+	c.generation++
+	c.mu.Unlock()
+
+	for _, fn := range c.onInvalidate {
+		fn(uuid.NullUUID{UUID: userID, Valid: true})
+	}
+}
+
+// InvalidateAll drops every cached entry, for when invalidations may have
+// been missed.
+func (c *VersionCache) InvalidateAll() {
+	c.mu.Lock()
+	clear(c.entries)
+	c.generation++
+	c.mu.Unlock()
+
+	for _, fn := range c.onInvalidate {
+		fn(uuid.NullUUID{})
+	}
+}
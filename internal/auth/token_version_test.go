@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memVersionStore struct {
+	versions map[uuid.UUID]int32
+	sessions map[uuid.UUID][]uuid.UUID
+	lookups  int
+}
+
+func (s *memVersionStore) GetUserTokenVersion(ctx context.Context, id uuid.UUID) (int32, error) {
+	s.lookups++
+	v, ok := s.versions[id]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return v, nil
+}
+
+func (s *memVersionStore) ListSessionIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return s.sessions[userID], nil
+}
+
+func TestValidateJWTTokenVersion(t *testing.T) {
+	now := time.Now()
+	m := newTestKeyManager(t, &memKeyStore{}, AlgEdDSA, &now)
+	if err := m.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, sessionID := uuid.New(), uuid.New()
+	store := &memVersionStore{
+		versions: map[uuid.UUID]int32{userID: 3},
+		sessions: map[uuid.UUID][]uuid.UUID{userID: {sessionID}},
+	}
+	versions := NewVersionCache(store, time.Minute)
+
+	token, err := MakeJWT(userID, sessionID, 3, m, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateJWT(token, m, versions); err != nil {
+		t.Fatalf("current version: %v", err)
+	}
+
+	store.versions[userID] = 4
+	versions.Invalidate(userID)
+	if _, err := ValidateJWT(token, m, versions); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("old version: err = %v, want ErrTokenRevoked", err)
+	}
+
+	delete(store.versions, userID)
+	versions.Invalidate(userID)
+	newer, err := MakeJWT(userID, sessionID, 4, m, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateJWT(newer, m, versions); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("banned user: err = %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestValidateJWTSession(t *testing.T) {
+	now := time.Now()
+	m := newTestKeyManager(t, &memKeyStore{}, AlgEdDSA, &now)
+	if err := m.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, kept, ended := uuid.New(), uuid.New(), uuid.New()
+	store := &memVersionStore{
+		versions: map[uuid.UUID]int32{userID: 0},
+		sessions: map[uuid.UUID][]uuid.UUID{userID: {kept, ended}},
+	}
+	versions := NewVersionCache(store, time.Minute)
+
+	keptToken, err := MakeJWT(userID, kept, 0, m, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endedToken, err := MakeJWT(userID, ended, 0, m, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{keptToken, endedToken} {
+		if _, err := ValidateJWT(token, m, versions); err != nil {
+			t.Fatalf("open session: %v", err)
+		}
+	}
+
+	store.sessions[userID] = []uuid.UUID{kept}
+	versions.Invalidate(userID)
+	if _, err := ValidateJWT(endedToken, m, versions); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("ended session: err = %v, want ErrTokenRevoked", err)
+	}
+	if _, err := ValidateJWT(keptToken, m, versions); err != nil {
+		t.Errorf("other session: %v", err)
+	}
+}
+
+func TestVersionCacheNewSession(t *testing.T) {
+	ctx := context.Background()
+	userID, first := uuid.New(), uuid.New()
+	store := &memVersionStore{
+		versions: map[uuid.UUID]int32{userID: 0},
+		sessions: map[uuid.UUID][]uuid.UUID{userID: {first}},
+	}
+	c := NewVersionCache(store, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	if ok, _ := c.SessionActive(ctx, userID, first, now); !ok {
+		t.Fatal("SessionActive() = false for an open session")
+	}
+
+	// A session started on another instance after the list was cached.
+	second := uuid.New()
+	store.sessions[userID] = []uuid.UUID{first, second}
+	now = now.Add(time.Second)
+	if ok, _ := c.SessionActive(ctx, userID, second, now); !ok {
+		t.Error("SessionActive() = false for a session newer than the cache")
+	}
+
+	// A token older than the cached list is not looked up again.
+	lookups := store.lookups
+	if ok, _ := c.SessionActive(ctx, userID, uuid.New(), now.Add(-time.Second)); ok {
+		t.Error("SessionActive() = true for an unknown session")
+	}
+	if store.lookups != lookups {
+		t.Errorf("%d lookups for a session missing from a fresh list, want 0", store.lookups-lookups)
+	}
+}
+
+func TestVersionCacheOnInvalidate(t *testing.T) {
+	c := NewVersionCache(&memVersionStore{}, time.Minute)
+	var got []uuid.NullUUID
+	c.OnInvalidate(func(userID uuid.NullUUID) {
+		got = append(got, userID)
+	})
+
+	userID := uuid.New()
+	c.Invalidate(userID)
+	c.InvalidateAll()
+	want := []uuid.NullUUID{{UUID: userID, Valid: true}, {}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("callbacks got %v, want %v", got, want)
+	}
+}
+
+func TestVersionCache(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	store := &memVersionStore{versions: map[uuid.UUID]int32{userID: 1}}
+	c := NewVersionCache(store, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for range 3 {
+		v, err := c.TokenVersion(ctx, userID)
+		if err != nil || v != 1 {
+			t.Fatalf("TokenVersion() = %d, %v, want 1", v, err)
+		}
+	}
+	if store.lookups != 1 {
+		t.Errorf("%d lookups, want 1", store.lookups)
+	}
+
+	// Without an invalidation the change shows once the entry expires.
+	store.versions[userID] = 2
+	now = now.Add(time.Minute)
+	if v, _ := c.TokenVersion(ctx, userID); v != 2 {
+		t.Errorf("after expiry TokenVersion() = %d, want 2", v)
+	}
+
+	store.versions[userID] = 3
+	c.InvalidateAll()
+	if v, _ := c.TokenVersion(ctx, userID); v != 3 {
+		t.Errorf("after InvalidateAll TokenVersion() = %d, want 3", v)
+	}
+
+	// Missing users are cached too, so revoked tokens do not hit the store.
+	unknown := uuid.New()
+	lookups := store.lookups
+	for range 2 {
+		if _, err := c.TokenVersion(ctx, unknown); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("unknown user: err = %v, want ErrTokenRevoked", err)
+		}
+	}
+	if store.lookups != lookups+1 {
+		t.Errorf("%d lookups for an unknown user, want 1", store.lookups-lookups)
+	}
+}
+
+// racingStore invalidates the cache while a lookup is in flight, as a
+// notification arriving between the read and the store would.
+type racingStore struct {
+	cache   *VersionCache
+	version int32
+}
+
+func (s *racingStore) GetUserTokenVersion(ctx context.Context, id uuid.UUID) (int32, error) {
+	v := s.version
+	s.version++
+	s.cache.Invalidate(id)
+	return v, nil
+}
+
+func (s *racingStore) ListSessionIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return nil, nil
+}
+
+func TestVersionCacheInvalidateDuringLookup(t *testing.T) {
+	store := &racingStore{}
+	c := NewVersionCache(store, time.Minute)
+	store.cache = c
+	userID := uuid.New()
+
+	if v, _ := c.TokenVersion(context.Background(), userID); v != 0 {
+		t.Fatalf("TokenVersion() = %d, want 0", v)
+	}
+	if v, _ := c.TokenVersion(context.Background(), userID); v != 1 {
+		t.Errorf("stale version cached: TokenVersion() = %d, want 1", v)
+	}
+}
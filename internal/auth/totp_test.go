@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// The SHA-1 test vectors of RFC 6238, appendix B, cut to six digits.
+func TestTOTPCode(t *testing.T) {
+	key := []byte("12345678901234567890")
+	tests := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range tests {
+		if got := totpCode(key, unix/totpPeriod); got != want {
+			t.Errorf("code at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	step := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name string
+		code string
+		ok   bool
+	}{
+		{name: "current", code: totpCode(key, step), ok: true},
+		{name: "previous step", code: totpCode(key, step-1), ok: true},
+		{name: "next step", code: totpCode(key, step+1), ok: true},
+		{name: "too old", code: totpCode(key, step-2), ok: false},
+		{name: "too new", code: totpCode(key, step+2), ok: false},
+		{name: "empty", code: "", ok: false},
+		{name: "too long", code: totpCode(key, step) + "0", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ValidateTOTP(secret, tt.code, now)
+			if ok != tt.ok {
+				t.Fatalf("ValidateTOTP() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && (got < step-totpSkew || got > step+totpSkew) {
+				t.Errorf("ValidateTOTP() step = %d, current step %d", got, step)
+			}
+		})
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	u, err := url.Parse(TOTPURI("JBSWY3DPEHPK3PXP", "Chirpy", "a@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Chirpy:a@example.com" {
+		t.Errorf("uri = %s", u)
+	}
+	if q := u.Query(); q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "Chirpy" || q.Get("digits") != "6" {
+		t.Errorf("query = %v", q)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if len(c) != 19 || seen[c] {
+			t.Errorf("bad or repeated code %q", c)
+		}
+		seen[c] = true
+	}
+
+	typed := "ABCD EFGH-ijkl mnop"
+	if got := NormalizeRecoveryCode(typed); got != "abcd-efgh-ijkl-mnop" {
+		t.Errorf("NormalizeRecoveryCode(%q) = %q", typed, got)
+	}
+}
@@ -7,30 +7,56 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countChirpsByAuthor = `-- name: CountChirpsByAuthor :one
+select count(*) from chirps c
+where c.user_id = $1
+`
+
+func (q *Queries) CountChirpsByAuthor(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByAuthor, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirps = `-- name: CreateChirps :one
 INSERT INTO chirps (
-   id, user_id, body ,created_at, updated_at
+   id, user_id, body, parent_id, root_id, rechirp_of_id, quote_of_id, is_quote, created_at, updated_at
 ) VALUES ( 
     gen_random_uuid(),
     $1,
     $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
     NOW(),
     NOW()
 )
-RETURNING id, user_id, body, created_at, updated_at
+RETURNING id, user_id, body, created_at, updated_at,
+    parent_id, root_id, rechirp_of_id, quote_of_id, is_quote
 `
 
 type CreateChirpsParams struct {
-	UserID uuid.UUID
-	Body   string
+	UserID      uuid.UUID
+	Body        string
+	ParentID    uuid.NullUUID
+	RootID      uuid.NullUUID
+	RechirpOfID uuid.NullUUID
+	QuoteOfID   uuid.NullUUID
+	IsQuote     bool
 }
 
 func (q *Queries) CreateChirps(ctx context.Context, arg CreateChirpsParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirps, arg.UserID, arg.Body)
+	row := q.db.QueryRowContext(ctx, createChirps, arg.UserID, arg.Body, arg.ParentID, arg.RootID, arg.RechirpOfID, arg.QuoteOfID, arg.IsQuote)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -38,6 +64,11 @@ func (q *Queries) CreateChirps(ctx context.Context, arg CreateChirpsParams) (Chi
 		&i.Body,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ParentID,
+		&i.RootID,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.IsQuote,
 	)
 	return i, err
 }
@@ -58,13 +89,66 @@ func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error
 	return err
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, user_id, body, created_at, updated_at FROM chirps c
-order by c.created_at ASC
+const getChirp = `-- name: GetChirp :one
+select c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+from chirps c
+where c.id = $1
+`
+
+func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ParentID,
+		&i.RootID,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.IsQuote,
+	)
+	return i, err
+}
+
+const getChirpForUpdate = `-- name: GetChirpForUpdate :one
+select c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+from chirps c
+where c.id = $1
+for update
+`
+
+func (q *Queries) GetChirpForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ParentID,
+		&i.RootID,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.IsQuote,
+	)
+	return i, err
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+select c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+from chirps c
+where c.id = ANY($1::uuid[])
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
@@ -78,6 +162,11 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.Body,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.ParentID,
+			&i.RootID,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.IsQuote,
 		); err != nil {
 			return nil, err
 		}
@@ -92,14 +181,100 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getAllChirpsByAuthor = `-- name: GetAllChirpsByAuthor :many
-SELECT id, user_id, body, created_at, updated_at FROM chirps c
-where c.user_id = $1
-order by c.created_at ASC
+const getThread = `-- name: GetThread :many
+WITH RECURSIVE thread AS (
+    SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+        c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote, 0 AS depth
+    FROM chirps c
+    where c.id = $1
+    UNION ALL
+    SELECT child.id, child.user_id, child.body, child.created_at, child.updated_at,
+        child.parent_id, child.root_id, child.rechirp_of_id, child.quote_of_id, child.is_quote, thread.depth + 1
+    FROM chirps child
+    JOIN thread ON child.parent_id = thread.id
+)
+SELECT t.id, t.user_id, t.body, t.created_at, t.updated_at,
+    t.parent_id, t.root_id, t.rechirp_of_id, t.quote_of_id, t.is_quote, t.depth, (
+    SELECT count(*) FROM chirps r
+    where r.parent_id = t.id
+) AS reply_count
+FROM thread t
+order by t.depth ASC, t.created_at ASC, t.id ASC
 `
 
-func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirpsByAuthor, userID)
+type GetThreadRow struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Body        string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	ParentID    uuid.NullUUID
+	RootID      uuid.NullUUID
+	RechirpOfID uuid.NullUUID
+	QuoteOfID   uuid.NullUUID
+	IsQuote     bool
+	Depth       int32
+	ReplyCount  int64
+}
+
+func (q *Queries) GetThread(ctx context.Context, id uuid.UUID) ([]GetThreadRow, error) {
+	rows, err := q.db.QueryContext(ctx, getThread, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetThreadRow
+	for rows.Next() {
+		var i GetThreadRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ParentID,
+			&i.RootID,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.IsQuote,
+			&i.Depth,
+			&i.ReplyCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpRepliesAsc = `-- name: ListChirpRepliesAsc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM chirps c
+where c.parent_id = $1
+and (
+    $2::timestamp is null
+    or (c.created_at, c.id) > ($2, $3::uuid)
+)
+order by c.created_at ASC, c.id ASC
+limit $4
+`
+
+type ListChirpRepliesAscParams struct {
+	ParentID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListChirpRepliesAsc(ctx context.Context, arg ListChirpRepliesAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpRepliesAsc, arg.ParentID, arg.CursorCreatedAt, arg.CursorID, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +288,11 @@ func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([
 			&i.Body,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.ParentID,
+			&i.RootID,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.IsQuote,
 		); err != nil {
 			return nil, err
 		}
@@ -127,13 +307,183 @@ func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([
 	return items, nil
 }
 
-const getChirp = `-- name: GetChirp :one
-select id, user_id, body, created_at, updated_at from chirps c
-where c.id = $1
+const listChirpRepliesDesc = `-- name: ListChirpRepliesDesc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM chirps c
+where c.parent_id = $1
+and (
+    $2::timestamp is null
+    or (c.created_at, c.id) < ($2, $3::uuid)
+)
+order by c.created_at DESC, c.id DESC
+limit $4
 `
 
-func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirp, id)
+type ListChirpRepliesDescParams struct {
+	ParentID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListChirpRepliesDesc(ctx context.Context, arg ListChirpRepliesDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpRepliesDesc, arg.ParentID, arg.CursorCreatedAt, arg.CursorID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ParentID,
+			&i.RootID,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.IsQuote,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM chirps c
+where ($1::uuid is null or c.user_id = $1)
+and (
+    $2::timestamp is null
+    or (c.created_at, c.id) > ($2, $3::uuid)
+)
+order by c.created_at ASC, c.id ASC
+limit $4
+`
+
+type ListChirpsAscParams struct {
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc, arg.AuthorID, arg.CursorCreatedAt, arg.CursorID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ParentID,
+			&i.RootID,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.IsQuote,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM chirps c
+where ($1::uuid is null or c.user_id = $1)
+and (
+    $2::timestamp is null
+    or (c.created_at, c.id) < ($2, $3::uuid)
+)
+order by c.created_at DESC, c.id DESC
+limit $4
+`
+
+type ListChirpsDescParams struct {
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageLimit       int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc, arg.AuthorID, arg.CursorCreatedAt, arg.CursorID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ParentID,
+			&i.RootID,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.IsQuote,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+set body = $1, updated_at = NOW()
+where id = $2
+RETURNING id, user_id, body, created_at, updated_at,
+    parent_id, root_id, rechirp_of_id, quote_of_id, is_quote
+`
+
+type UpdateChirpBodyParams struct {
+	Body string
+	ID   uuid.UUID
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.ID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -141,6 +491,11 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.Body,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ParentID,
+		&i.RootID,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.IsQuote,
 	)
 	return i, err
 }
@@ -6,26 +6,219 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Attachment struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	ChirpID      uuid.NullUUID
+	Position     int32
+	StorageKey   string
+	ThumbnailKey string
+	ContentType  string
+	Width        int32
+	Height       int32
+	CreatedAt    time.Time
+}
+
+type Bookmark struct {
+	UserID       uuid.UUID
+	ChirpID      uuid.UUID
+	CollectionID uuid.NullUUID
+	CreatedAt    time.Time
+}
+
+type BookmarkCollection struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
 type Chirp struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Body        string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	ParentID    uuid.NullUUID
+	RootID      uuid.NullUUID
+	RechirpOfID uuid.NullUUID
+	QuoteOfID   uuid.NullUUID
+	IsQuote     bool
+}
+
+type ChirpEvent struct {
+	ID        int64
+	Type      string
+	ChirpID   uuid.UUID
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
+	TxID      int64
+	RootID    uuid.NullUUID
+}
+
+type ChirpFlag struct {
 	ID        uuid.UUID
+	ChirpID   uuid.UUID
+	Reasons   []string
+	CreatedAt time.Time
+}
+
+type ChirpLike struct {
+	ChirpID   uuid.UUID
 	UserID    uuid.UUID
-	Body      string
+	CreatedAt time.Time
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
+type ChirpTag struct {
+	ChirpID   uuid.UUID
+	TagID     uuid.UUID
+	CreatedAt time.Time
+}
+
+type Draft struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	Payload       json.RawMessage
+	ScheduledAt   sql.NullTime
+	LastError     sql.NullString
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Attempts      int32
+	NextAttemptAt sql.NullTime
+}
+
+type EmailChange struct {
+	UserID    uuid.UUID
+	NewEmail  string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Mention struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type MfaChallenge struct {
+	TokenHash   string
+	UserID      uuid.UUID
+	DeviceLabel string
+	Attempts    int32
+	ExpiresAt   time.Time
+}
+
+type ModerationWord struct {
+	Word      string
+	Action    string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+type Notification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ActorID   uuid.NullUUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	CreatedAt time.Time
+	ReadAt    sql.NullTime
+}
+
+type PinnedChirp struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Poll struct {
+	ChirpID   uuid.UUID
+	ClosesAt  time.Time
+	CreatedAt time.Time
+}
+
+type PollOption struct {
+	ID       uuid.UUID
+	ChirpID  uuid.UUID
+	Position int32
+	Text     string
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	OptionID  uuid.UUID
+	CreatedAt time.Time
+}
+
+type RecoveryCode struct {
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+}
+
 type RefreshToken struct {
-	Token     string
+	TokenHash string
 	UserID    uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	SessionID uuid.UUID
+	UsedAt    sql.NullTime
+}
+
+type Session struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	UserAgent   string
+	Ip          string
+	DeviceLabel string
+	CreatedAt   time.Time
+	LastUsedAt  time.Time
+	ExpiresAt   time.Time
+}
+
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey []byte
+	CreatedAt  time.Time
+}
+
+type Tag struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedAt time.Time
+}
+
+type TimelineEntry struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
 }
 
 type User struct {
@@ -35,4 +228,22 @@ type User struct {
 	UpdatedAt      time.Time
 	HashedPassword string
 	IsChirpyRed    bool
+	Handle         sql.NullString
+	DisplayName    string
+	Bio            string
+	AvatarUrl      string
+	Location       string
+	Website        string
+	TokenVersion   int32
+	BannedAt       sql.NullTime
+}
+
+type UserTotp struct {
+	UserID         uuid.UUID
+	Secret         []byte
+	EnabledAt      sql.NullTime
+	LastStep       int64
+	FailedAttempts int32
+	LockedUntil    sql.NullTime
+	CreatedAt      time.Time
 }
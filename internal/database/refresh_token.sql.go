@@ -13,64 +13,79 @@ import (
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens (
-    token, user_id, expires_at, created_at, updated_at
+    token_hash, user_id, session_id, expires_at, created_at, updated_at
 ) VALUES (
     $1,
     $2,
+    $3,
     NOW() + INTERVAL '60 days',
     NOW(),
     NOW()
     )
-    RETURNING token, user_id, expires_at, revoked_at, created_at, updated_at
+    RETURNING token_hash, user_id, expires_at, revoked_at, created_at, updated_at, session_id, used_at
 `
 
 type CreateRefreshTokenParams struct {
-	Token  string
-	UserID uuid.UUID
+	TokenHash string
+	UserID    uuid.UUID
+	SessionID uuid.UUID
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.Token, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.TokenHash, arg.UserID, arg.SessionID)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.SessionID,
+		&i.UsedAt,
 	)
 	return i, err
 }
 
 const getRefreshToken = `-- name: GetRefreshToken :one
-select token, user_id, expires_at, revoked_at, created_at, updated_at from refresh_tokens rt 
-where rt."token" = $1
+select token_hash, user_id, expires_at, revoked_at, created_at, updated_at, session_id, used_at from refresh_tokens rt 
+where rt.token_hash = $1
 and rt.revoked_at is null 
 and rt.expires_at > now()
 `
 
-func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, getRefreshToken, token)
+// GetRefreshToken also returns tokens that were already used, so that a
+// replayed token can be recognised.
+func (q *Queries) GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshToken, tokenHash)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.SessionID,
+		&i.UsedAt,
 	)
 	return i, err
 }
 
-const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
-update refresh_tokens 
-set revoked_at = now(), updated_at = now()
-where "token" =$1
+const useRefreshToken = `-- name: UseRefreshToken :execrows
+update refresh_tokens
+set used_at = now(), updated_at = now()
+where token_hash = $1
+and used_at is null
+and revoked_at is null
 `
 
-func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) error {
-	_, err := q.db.ExecContext(ctx, revokeRefreshToken, token)
-	return err
+// UseRefreshToken marks a token as used. Of two requests racing with the
+// same token only one sees a row affected.
+func (q *Queries) UseRefreshToken(ctx context.Context, tokenHash string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useRefreshToken, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
@@ -7,29 +7,61 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const banUser = `-- name: BanUser :execrows
+UPDATE users
+set banned_at = NOW(), token_version = token_version + 1
+where id = $1
+and banned_at is null
+`
+
+func (q *Queries) BanUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, banUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const bumpTokenVersion = `-- name: BumpTokenVersion :one
+UPDATE users
+set token_version = token_version + 1
+where id = $1
+RETURNING token_version
+`
+
+func (q *Queries) BumpTokenVersion(ctx context.Context, id uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, bumpTokenVersion, id)
+	var token_version int32
+	err := row.Scan(&token_version)
+	return token_version, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
-   id, email, hashed_password, created_at, updated_at
+   id, email, hashed_password, handle, created_at, updated_at
 ) VALUES ( 
     gen_random_uuid(),
     $1,
     $2,
+    $3,
     NOW(),
     NOW()
-) returning id, email, created_at, updated_at, hashed_password, is_chirpy_red
+) returning id, email, created_at, updated_at, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, location, website, token_version, banned_at
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Handle         sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Handle)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -38,6 +70,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.Website,
+		&i.TokenVersion,
+		&i.BannedAt,
 	)
 	return i, err
 }
@@ -51,8 +91,35 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const getUser = `-- name: GetUser :one
+SELECT id, email, created_at, updated_at, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, location, website, token_version, banned_at FROM users u
+where u.id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.Website,
+		&i.TokenVersion,
+		&i.BannedAt,
+	)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, created_at, updated_at, hashed_password, is_chirpy_red FROM users u
+SELECT id, email, created_at, updated_at, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, location, website, token_version, banned_at FROM users u
 where u.email = $1
 `
 
@@ -66,24 +133,116 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.Website,
+		&i.TokenVersion,
+		&i.BannedAt,
 	)
 	return i, err
 }
 
-const updateChirpyRed = `-- name: UpdateChirpyRed :one
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, email, created_at, updated_at, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, location, website, token_version, banned_at FROM users u
+where lower(u.handle) = lower($1)
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.Website,
+		&i.TokenVersion,
+		&i.BannedAt,
+	)
+	return i, err
+}
+
+const getUserForUpdate = `-- name: GetUserForUpdate :one
+SELECT id, email, created_at, updated_at, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, location, website, token_version, banned_at FROM users u
+where u.id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetUserForUpdate(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserForUpdate, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.Website,
+		&i.TokenVersion,
+		&i.BannedAt,
+	)
+	return i, err
+}
+
+const getUserTokenVersion = `-- name: GetUserTokenVersion :one
+SELECT token_version FROM users u
+where u.id = $1
+and u.banned_at is null
+`
+
+// GetUserTokenVersion finds no rows for banned users.
+func (q *Queries) GetUserTokenVersion(ctx context.Context, id uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getUserTokenVersion, id)
+	var token_version int32
+	err := row.Scan(&token_version)
+	return token_version, err
+}
+
+const unbanUser = `-- name: UnbanUser :execrows
 UPDATE users
-set is_chirpy_red = $1
+set banned_at = NULL
+where id = $1
+and banned_at is not null
+`
+
+func (q *Queries) UnbanUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unbanUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUserEmail = `-- name: UpdateUserEmail :one
+UPDATE users
+set email = $1, updated_at = NOW()
 where id = $2
-RETURNING id, email, created_at, updated_at, hashed_password, is_chirpy_red
+RETURNING id, email, created_at, updated_at, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, location, website, token_version, banned_at
 `
 
-type UpdateChirpyRedParams struct {
-	IsChirpyRed bool
-	ID          uuid.UUID
+type UpdateUserEmailParams struct {
+	Email string
+	ID    uuid.UUID
 }
 
-func (q *Queries) UpdateChirpyRed(ctx context.Context, arg UpdateChirpyRedParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateChirpyRed, arg.IsChirpyRed, arg.ID)
+func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserEmail, arg.Email, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -92,25 +251,115 @@ func (q *Queries) UpdateChirpyRed(ctx context.Context, arg UpdateChirpyRedParams
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.Website,
+		&i.TokenVersion,
+		&i.BannedAt,
 	)
 	return i, err
 }
 
-const updateUserInfo = `-- name: UpdateUserInfo :one
+const updateUserHandle = `-- name: UpdateUserHandle :one
 UPDATE users
-set email = $1, hashed_password = $2
-where id = $3
-RETURNING id, email, created_at, updated_at, hashed_password, is_chirpy_red
+set handle = $1, updated_at = NOW()
+where id = $2
+RETURNING id, email, created_at, updated_at, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, location, website, token_version, banned_at
 `
 
-type UpdateUserInfoParams struct {
-	Email          string
+type UpdateUserHandleParams struct {
+	Handle sql.NullString
+	ID     uuid.UUID
+}
+
+func (q *Queries) UpdateUserHandle(ctx context.Context, arg UpdateUserHandleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserHandle, arg.Handle, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.Website,
+		&i.TokenVersion,
+		&i.BannedAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+set hashed_password = $1, token_version = token_version + 1, updated_at = NOW()
+where id = $2
+RETURNING id, email, created_at, updated_at, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, location, website, token_version, banned_at
+`
+
+type UpdateUserPasswordParams struct {
 	HashedPassword string
 	ID             uuid.UUID
 }
 
-func (q *Queries) UpdateUserInfo(ctx context.Context, arg UpdateUserInfoParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUserInfo, arg.Email, arg.HashedPassword, arg.ID)
+// UpdateUserPassword also bumps the token version, so access tokens issued
+// with the old password stop working.
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.HashedPassword, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.Website,
+		&i.TokenVersion,
+		&i.BannedAt,
+	)
+	return i, err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+set handle = coalesce($1, handle),
+    display_name = coalesce($2, display_name),
+    bio = coalesce($3, bio),
+    avatar_url = coalesce($4, avatar_url),
+    location = coalesce($5, location),
+    website = coalesce($6, website),
+    updated_at = NOW()
+where id = $7
+RETURNING id, email, created_at, updated_at, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, location, website, token_version, banned_at
+`
+
+type UpdateUserProfileParams struct {
+	Handle      sql.NullString
+	DisplayName sql.NullString
+	Bio         sql.NullString
+	AvatarUrl   sql.NullString
+	Location    sql.NullString
+	Website     sql.NullString
+	ID          uuid.UUID
+}
+
+// UpdateUserProfile sets the fields that are not null and leaves the rest
+// untouched.
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile, arg.Handle, arg.DisplayName, arg.Bio, arg.AvatarUrl, arg.Location, arg.Website, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -119,6 +368,30 @@ func (q *Queries) UpdateUserInfo(ctx context.Context, arg UpdateUserInfoParams)
 		&i.UpdatedAt,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Location,
+		&i.Website,
+		&i.TokenVersion,
+		&i.BannedAt,
 	)
 	return i, err
 }
+
+const upgradeToChirpyRed = `-- name: UpgradeToChirpyRed :execrows
+UPDATE users
+set is_chirpy_red = true
+where id = $1
+and is_chirpy_red = false
+`
+
+// UpgradeToChirpyRed finds no rows for users that are Red already.
+func (q *Queries) UpgradeToChirpyRed(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, upgradeToChirpyRed, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,98 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	from := &mail.Address{Name: "Chirpy", Address: "no-reply@chirpy.test"}
+	to := &mail.Address{Address: "user@example.com"}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	msg, err := format(from, to, Message{Subject: "Confirm your email", Body: "line one\nline two"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "From: \"Chirpy\" <no-reply@chirpy.test>\r\n" +
+		"To: <user@example.com>\r\n" +
+		"Subject: Confirm your email\r\n" +
+		"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"line one\r\nline two"
+	if string(msg) != want {
+		t.Errorf("got\n%q\nwant\n%q", msg, want)
+	}
+}
+
+func TestFormatEncodesSubject(t *testing.T) {
+	from := &mail.Address{Address: "no-reply@chirpy.test"}
+	to := &mail.Address{Address: "user@example.com"}
+
+	msg, err := format(from, to, Message{Subject: "Bestätigen"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(msg), "Subject: =?utf-8?q?Best=C3=A4tigen?=\r\n") {
+		t.Errorf("subject not encoded: %q", msg)
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	from := &mail.Address{Address: "no-reply@chirpy.test"}
+	to := &mail.Address{Address: "user@example.com"}
+
+	_, err := format(from, to, Message{Subject: "Hi\r\nBcc: victim@example.com"}, time.Now())
+	if !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("format error = %v, want ErrInvalidHeader", err)
+	}
+
+	err = LogMailer{}.Send(context.Background(), Message{To: "user@example.com\nBcc: victim@example.com"})
+	if !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("LogMailer error = %v, want ErrInvalidHeader", err)
+	}
+
+	err = NewSMTPMailer(SMTPConfig{From: "no-reply@chirpy.test"}).Send(context.Background(), Message{To: "user@example.com\r\nBcc: victim@example.com"})
+	if err == nil {
+		t.Error("SMTPMailer accepted a recipient with a line break")
+	}
+}
+
+func TestSMTPMailerTimeout(t *testing.T) {
+	// A server that accepts the connection but never greets.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	m := NewSMTPMailer(SMTPConfig{Addr: ln.Addr().String(), From: "no-reply@chirpy.test"})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = m.Send(ctx, Message{To: "user@example.com", Subject: "Hi"})
+	if err == nil {
+		t.Fatal("Send() succeeded without a server reply")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send() took %s, want it to stop at the context deadline", elapsed)
+	}
+}
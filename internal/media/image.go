@@ -0,0 +1,167 @@
+// Package media processes uploaded images and stores them as blobs.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxPixels bounds the decoded size of an image, so a small file can not
+	// expand into an enormous bitmap.
+	MaxPixels     = 24_000_000
+	ThumbnailSize = 320
+	jpegQuality   = 85
+)
+
+var (
+	ErrUnsupportedType = errors.New("Only JPEG, PNG and GIF images are supported")
+	ErrTooLarge        = errors.New("Image dimensions are too large")
+)
+
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+type Processed struct {
+	Image     Image
+	Thumbnail Image
+}
+
+// Process validates an upload by its content rather than its declared type
+// and re-encodes it. Re-encoding drops all metadata, EXIF location data
+// included, so the EXIF orientation is applied to the pixels first. GIFs
+// keep only their first frame.
+func Process(data []byte) (Processed, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return Processed{}, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, ErrUnsupportedType
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return Processed{}, ErrTooLarge
+	}
+
+	var src image.Image
+	switch contentType {
+	case "image/jpeg":
+		src, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		src, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		src, err = gif.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return Processed{}, ErrUnsupportedType
+	}
+
+	img := toNRGBA(src)
+	if contentType == "image/jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+	thumb := thumbnail(img, ThumbnailSize)
+
+	// Anything that may have transparency is stored as PNG.
+	outType := "image/png"
+	if contentType == "image/jpeg" {
+		outType = "image/jpeg"
+	}
+
+	full, err := encode(img, outType)
+	if err != nil {
+		return Processed{}, err
+	}
+	small, err := encode(thumb, outType)
+	if err != nil {
+		return Processed{}, err
+	}
+
+	return Processed{Image: full, Thumbnail: small}, nil
+}
+
+func encode(img *image.NRGBA, contentType string) (Image, error) {
+	buf := &bytes.Buffer{}
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(buf, img)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+
+	return Image{
+		Data:        buf.Bytes(),
+		ContentType: contentType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}, nil
+}
+
+func toNRGBA(src image.Image) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// thumbnail scales img down to fit in a size x size square, averaging the
+// source pixels behind each destination pixel. Smaller images are returned
+// unchanged.
+func thumbnail(img *image.NRGBA, size int) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	tw, th = max(tw, 1), max(th, 1)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, max((x+1)*w/tw, x*w/tw+1)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				i := img.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(img.Pix[i])
+					g += int(img.Pix[i+1])
+					b += int(img.Pix[i+2])
+					a += int(img.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			o := dst.PixOffset(x, y)
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(b / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,175 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// withOrientation inserts an EXIF segment holding only an orientation tag
+// right after the JPEG start of image marker.
+func withOrientation(t *testing.T, jpg []byte, orientation uint16) []byte {
+	t.Helper()
+
+	tiff := []byte("II*\x00")
+	tiff = binary.LittleEndian.AppendUint32(tiff, 8)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xff, 0xe1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, app1...)
+	return append(out, jpg[2:]...)
+}
+
+func TestProcessJPEG(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := jpeg.Encode(buf, testImage(40, 20), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := withOrientation(t, buf.Bytes(), 6)
+	if jpegOrientation(data) != 6 {
+		t.Fatalf("jpegOrientation() = %d, want 6", jpegOrientation(data))
+	}
+
+	p, err := Process(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Image.ContentType != "image/jpeg" {
+		t.Errorf("ContentType = %q", p.Image.ContentType)
+	}
+	// Orientation 6 is rotated 90 degrees, so the stored image is upright.
+	if p.Image.Width != 20 || p.Image.Height != 40 {
+		t.Errorf("size = %dx%d, want 20x40", p.Image.Width, p.Image.Height)
+	}
+	if bytes.Contains(p.Image.Data, []byte("Exif")) {
+		t.Error("EXIF data was not stripped")
+	}
+	if jpegOrientation(p.Image.Data) != 1 {
+		t.Error("orientation tag was kept")
+	}
+}
+
+func TestProcessThumbnail(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, testImage(640, 320))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Process(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Image.ContentType != "image/png" || p.Thumbnail.ContentType != "image/png" {
+		t.Errorf("content types = %q, %q", p.Image.ContentType, p.Thumbnail.ContentType)
+	}
+	if p.Thumbnail.Width != ThumbnailSize || p.Thumbnail.Height != ThumbnailSize/2 {
+		t.Errorf("thumbnail size = %dx%d", p.Thumbnail.Width, p.Thumbnail.Height)
+	}
+
+	thumb, err := png.Decode(bytes.NewReader(p.Thumbnail.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumb.Bounds().Dx() != p.Thumbnail.Width {
+		t.Errorf("decoded thumbnail width = %d", thumb.Bounds().Dx())
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	_, err := Process([]byte("<html><script>alert(1)</script></html>"))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("html: error = %v, want ErrUnsupportedType", err)
+	}
+
+	// A PNG header claiming a huge image, with no pixel data behind it.
+	buf := &bytes.Buffer{}
+	png.Encode(buf, testImage(1, 1))
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 100000)
+	binary.BigEndian.PutUint32(data[20:], 100000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	_, err = Process(data)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("huge png: error = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	img := testImage(3, 2)
+	for o := 1; o <= 8; o++ {
+		got := orient(img, o)
+		w, h := got.Bounds().Dx(), got.Bounds().Dy()
+		if o >= 5 && (w != 2 || h != 3) || o < 5 && (w != 3 || h != 2) {
+			t.Errorf("orientation %d: size = %dx%d", o, w, h)
+		}
+	}
+
+	// Orientation 3 is a half turn.
+	got := orient(img, 3)
+	if got.NRGBAAt(0, 0) != img.NRGBAAt(2, 1) {
+		t.Error("orientation 3 did not rotate 180 degrees")
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocalStorage(dir, "/media")
+	ctx := context.Background()
+
+	err := s.Put(ctx, "user/a.png", []byte("data"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "user", "a.png"))
+	if err != nil || string(got) != "data" {
+		t.Fatalf("stored %q, %v", got, err)
+	}
+	if s.URL("user/a.png") != "/media/user/a.png" {
+		t.Errorf("URL() = %q", s.URL("user/a.png"))
+	}
+
+	err = s.Delete(ctx, "user/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Delete(ctx, "user/a.png")
+	if err != nil {
+		t.Errorf("deleting a missing blob: %v", err)
+	}
+
+	err = s.Put(ctx, "../escape.png", []byte("data"), "image/png")
+	if !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Put outside the directory: error = %v, want ErrInvalidKey", err)
+	}
+}
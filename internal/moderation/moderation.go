@@ -0,0 +1,100 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Action is what happens to a chirp that matches a rule. Actions are ordered
+// by severity, so the strongest match across a pipeline wins.
+type Action int
+
+const (
+	ActionAllow Action = iota
+	ActionMask
+	ActionFlag
+	ActionReject
+)
+
+// mask replaces matched words, the same way Chirpy always has.
+const mask = "****"
+
+var ErrRejected = errors.New("Chirp rejected by moderation")
+
+func (a Action) String() string {
+	switch a {
+	case ActionMask:
+		return "mask"
+	case ActionFlag:
+		return "flag"
+	case ActionReject:
+		return "reject"
+	default:
+		return "allow"
+	}
+}
+
+func ParseAction(s string) (Action, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "allow":
+		return ActionAllow, nil
+	case "", "mask":
+		return ActionMask, nil
+	case "flag":
+		return ActionFlag, nil
+	case "reject":
+		return ActionReject, nil
+	default:
+		return ActionAllow, fmt.Errorf("Unknown moderation action: %s", s)
+	}
+}
+
+// Result is the outcome of running a body through one or more filters.
+type Result struct {
+	Body    string
+	Action  Action
+	Reasons []string
+}
+
+func (r *Result) add(action Action, reason string) {
+	if action > r.Action {
+		r.Action = action
+	}
+	r.Reasons = append(r.Reasons, reason)
+}
+
+type Filter interface {
+	Filter(body string) Result
+}
+
+// Pipeline runs filters in order, each one seeing the body as masked by the
+// filters before it.
+type Pipeline struct {
+	filters []Filter
+}
+
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+// Run returns ErrRejected, wrapped with the reasons, as soon as a filter
+// rejects the body.
+func (p *Pipeline) Run(body string) (Result, error) {
+	result := Result{Body: body}
+
+	for _, f := range p.filters {
+		r := f.Filter(result.Body)
+		result.Body = r.Body
+		result.Reasons = append(result.Reasons, r.Reasons...)
+		if r.Action > result.Action {
+			result.Action = r.Action
+		}
+
+		if result.Action == ActionReject {
+			return result, fmt.Errorf("%w: %s", ErrRejected, strings.Join(result.Reasons, ", "))
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,145 @@
+package moderation
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWordListFilter(t *testing.T) {
+	wl := NewWordList(map[string]Action{
+		"kerfuffle": ActionMask,
+		"sharbert":  ActionMask,
+		"fornax":    ActionFlag,
+		"zorp":      ActionReject,
+	})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantBody   string
+		wantAction Action
+	}{
+		{
+			name:       "Clean body",
+			body:       "I had something interesting for breakfast",
+			wantBody:   "I had something interesting for breakfast",
+			wantAction: ActionAllow,
+		},
+		{
+			name:       "Mixed case",
+			body:       "This is a Kerfuffle opinion",
+			wantBody:   "This is a **** opinion",
+			wantAction: ActionMask,
+		},
+		{
+			name:       "Trailing punctuation",
+			body:       "What a kerfuffle! Sharbert, really.",
+			wantBody:   "What a ****! ****, really.",
+			wantAction: ActionMask,
+		},
+		{
+			name:       "Fullwidth letters",
+			body:       "ｋｅｒｆｕｆｆｌｅ again",
+			wantBody:   "**** again",
+			wantAction: ActionMask,
+		},
+		{
+			name:       "Accents and zero-width space",
+			body:       "k\u00e9rf\u200buffle",
+			wantBody:   "****",
+			wantAction: ActionMask,
+		},
+		{
+			name:       "Substring is not a match",
+			body:       "kerfuffles and sharberts",
+			wantBody:   "kerfuffles and sharberts",
+			wantAction: ActionAllow,
+		},
+		{
+			name:       "Flag keeps the body",
+			body:       "fornax and kerfuffle",
+			wantBody:   "fornax and ****",
+			wantAction: ActionFlag,
+		},
+		{
+			name:       "Reject wins",
+			body:       "zorp fornax",
+			wantBody:   "zorp fornax",
+			wantAction: ActionReject,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wl.Filter(tt.body)
+			if got.Body != tt.wantBody {
+				t.Errorf("Filter() body = %q, want %q", got.Body, tt.wantBody)
+			}
+			if got.Action != tt.wantAction {
+				t.Errorf("Filter() action = %v, want %v", got.Action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestWordListUpdates(t *testing.T) {
+	wl := NewWordList(nil)
+	wl.Set("Kerfuffle", ActionMask)
+
+	if got := wl.Filter("kerfuffle").Body; got != "****" {
+		t.Errorf("Filter() after Set = %q, want %q", got, "****")
+	}
+
+	wl.Remove("KERFUFFLE")
+	if got := wl.Filter("kerfuffle").Body; got != "kerfuffle" {
+		t.Errorf("Filter() after Remove = %q, want %q", got, "kerfuffle")
+	}
+}
+
+func TestPipelineRun(t *testing.T) {
+	p := NewPipeline(
+		NewWordList(map[string]Action{"kerfuffle": ActionMask}),
+		NewRegexFilter(
+			RegexRule{Pattern: regexp.MustCompile(`(?i)bit\.ly/\S+`), Action: ActionFlag},
+			RegexRule{Pattern: regexp.MustCompile(`a{10,}`), Action: ActionReject},
+		),
+	)
+
+	got, err := p.Run("kerfuffle at bit.ly/abc")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got.Body != "**** at bit.ly/abc" || got.Action != ActionFlag || len(got.Reasons) != 2 {
+		t.Errorf("Run() = %+v", got)
+	}
+
+	_, err = p.Run("aaaaaaaaaaaa")
+	if !errors.Is(err, ErrRejected) {
+		t.Errorf("Run() error = %v, want ErrRejected", err)
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	words, rules, err := LoadRules(strings.NewReader(`
+# banned words
+kerfuffle
+SharBert reject
+/(?i)bit\.ly\/\S+/ flag
+`))
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+	if words["kerfuffle"] != ActionMask || words["sharbert"] != ActionReject {
+		t.Errorf("LoadRules() words = %v", words)
+	}
+	if len(rules) != 1 || rules[0].Action != ActionFlag {
+		t.Errorf("LoadRules() rules = %v", rules)
+	}
+
+	_, _, err = LoadRules(strings.NewReader("kerfuffle explode"))
+	if err == nil {
+		t.Error("LoadRules() expected error for unknown action")
+	}
+}
@@ -0,0 +1,35 @@
+package moderation
+
+import "regexp"
+
+type RegexRule struct {
+	Pattern *regexp.Regexp
+	Action  Action
+}
+
+// RegexFilter applies rules to the raw body, for things a word list can not
+// express such as links or repeated characters.
+type RegexFilter struct {
+	rules []RegexRule
+}
+
+func NewRegexFilter(rules ...RegexRule) *RegexFilter {
+	return &RegexFilter{rules: rules}
+}
+
+func (f *RegexFilter) Filter(body string) Result {
+	result := Result{Body: body}
+
+	for _, rule := range f.rules {
+		if rule.Action == ActionAllow || !rule.Pattern.MatchString(result.Body) {
+			continue
+		}
+
+		result.add(rule.Action, "pattern: "+rule.Pattern.String())
+		if rule.Action == ActionMask {
+			result.Body = rule.Pattern.ReplaceAllString(result.Body, mask)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,79 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// LoadRules reads a rules file. Each non-empty line holds a word or a
+// /regular expression/ followed by an optional action (mask by default):
+//
+//	# comments start with a hash
+//	kerfuffle
+//	sharbert reject
+//	/(?i)bit\.ly\/\S+/ flag
+//
+// Words are returned normalized.
+func LoadRules(r io.Reader) (map[string]Action, []RegexRule, error) {
+	words := make(map[string]Action)
+	rules := make([]RegexRule, 0)
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			end := strings.LastIndex(line, "/")
+			if end == 0 {
+				return nil, nil, fmt.Errorf("line %d: unterminated pattern", lineNo)
+			}
+			pattern, err := regexp.Compile(line[1:end])
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			action, err := ParseAction(line[end+1:])
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			rules = append(rules, RegexRule{Pattern: pattern, Action: action})
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return nil, nil, fmt.Errorf("line %d: expected a word and an action", lineNo)
+		}
+		action := ActionMask
+		if len(fields) == 2 {
+			var err error
+			action, err = ParseAction(fields[1])
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		}
+		// Words are keyed the way the word list and the admin API look
+		// them up.
+		words[Normalize(fields[0])] = action
+	}
+
+	return words, rules, scanner.Err()
+}
+
+func LoadRulesFile(path string) (map[string]Action, []RegexRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	return LoadRules(f)
+}
@@ -0,0 +1,104 @@
+package moderation
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WordList matches whole words, ignoring case, punctuation around the word
+// and the tricks Normalize undoes. It is safe for concurrent use so the list
+// can be changed while chirps are being checked.
+type WordList struct {
+	mu    sync.RWMutex
+	words map[string]Action
+}
+
+func NewWordList(words map[string]Action) *WordList {
+	wl := &WordList{}
+	wl.Replace(words)
+	return wl
+}
+
+func (wl *WordList) Replace(words map[string]Action) {
+	normalized := make(map[string]Action, len(words))
+	for word, action := range words {
+		normalized[Normalize(word)] = action
+	}
+
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	wl.words = normalized
+}
+
+func (wl *WordList) Set(word string, action Action) {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	wl.words[Normalize(word)] = action
+}
+
+func (wl *WordList) Remove(word string) {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	delete(wl.words, Normalize(word))
+}
+
+type Word struct {
+	Word   string
+	Action Action
+}
+
+func (wl *WordList) Words() []Word {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	words := make([]Word, 0, len(wl.words))
+	for word, action := range wl.words {
+		words = append(words, Word{Word: word, Action: action})
+	}
+	sort.Slice(words, func(i, j int) bool {
+		return words[i].Word < words[j].Word
+	})
+
+	return words
+}
+
+func (wl *WordList) Filter(body string) Result {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	result := Result{}
+	var b strings.Builder
+	runes := []rune(body)
+
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		i = j
+
+		action, ok := wl.words[Normalize(word)]
+		if !ok || action == ActionAllow {
+			b.WriteString(word)
+			continue
+		}
+
+		result.add(action, "word: "+Normalize(word))
+		if action == ActionMask {
+			b.WriteString(mask)
+		} else {
+			b.WriteString(word)
+		}
+	}
+
+	result.Body = b.String()
+	return result
+}
@@ -0,0 +1,104 @@
+package notifications
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/database"
+)
+
+type Type string
+
+const (
+	TypeFollow       Type = "follow"
+	TypeLike         Type = "like"
+	TypeReply        Type = "reply"
+	TypeMention      Type = "mention"
+	TypeRechirp      Type = "rechirp"
+	TypeQuote        Type = "quote"
+	TypeSubscription Type = "subscription_upgraded"
+)
+
+type Event struct {
+	Type        Type
+	RecipientID uuid.UUID
+	ActorID     uuid.NullUUID
+	ChirpID     uuid.NullUUID
+}
+
+type Store interface {
+	CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error)
+}
+
+// Service records notifications off the request path. Emit never blocks:
+// events are queued and written by Run, so producers such as chirp creation
+// do not wait on the notifications table.
+type Service struct {
+	store     Store
+	events    chan Event
+	onCreated []func(database.Notification)
+}
+
+const writeTimeout = 5 * time.Second
+
+func NewService(store Store, buffer int) *Service {
+	return &Service{
+		store:  store,
+		events: make(chan Event, buffer),
+	}
+}
+
+// OnCreated registers a callback run by the worker after each notification
+// is stored. It must be called before Run.
+func (s *Service) OnCreated(fn func(database.Notification)) {
+	s.onCreated = append(s.onCreated, fn)
+}
+
+// Emit queues an event. Users are never notified about their own actions,
+// and events are dropped (and logged) if the queue is full.
+func (s *Service) Emit(e Event) {
+	if e.ActorID.Valid && e.ActorID.UUID == e.RecipientID {
+		return
+	}
+
+	select {
+	case s.events <- e:
+	default:
+		log.Printf("Notification queue full, dropping %s for %s\n", e.Type, e.RecipientID)
+	}
+}
+
+// Run writes queued events until ctx is cancelled.
+func (s *Service) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-s.events:
+			s.write(ctx, e)
+		}
+	}
+}
+
+func (s *Service) write(ctx context.Context, e Event) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	n, err := s.store.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  e.RecipientID,
+		ActorID: e.ActorID,
+		Type:    string(e.Type),
+		ChirpID: e.ChirpID,
+	})
+	if err != nil {
+		log.Printf("Error saving %s notification for %s: %s\n", e.Type, e.RecipientID, err)
+		return
+	}
+
+	for _, fn := range s.onCreated {
+		fn(n)
+	}
+}
@@ -0,0 +1,78 @@
+package notifications
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vystepanenko/Chirpy/internal/database"
+)
+
+type fakeStore struct {
+	mu    sync.Mutex
+	saved []database.CreateNotificationParams
+}
+
+func (f *fakeStore) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.saved = append(f.saved, arg)
+	return database.Notification{ID: uuid.New(), UserID: arg.UserID, Type: arg.Type}, nil
+}
+
+func TestServiceEmit(t *testing.T) {
+	store := &fakeStore{}
+	s := NewService(store, 10)
+
+	created := make(chan database.Notification, 10)
+	s.OnCreated(func(n database.Notification) {
+		created <- n
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	alice, bob := uuid.New(), uuid.New()
+	s.Emit(Event{Type: TypeLike, RecipientID: alice, ActorID: uuid.NullUUID{UUID: bob, Valid: true}})
+	s.Emit(Event{Type: TypeLike, RecipientID: alice, ActorID: uuid.NullUUID{UUID: alice, Valid: true}})
+	s.Emit(Event{Type: TypeSubscription, RecipientID: bob})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-created:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for notifications")
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.saved) != 2 {
+		t.Fatalf("saved %d notifications, want 2 (self notifications are skipped)", len(store.saved))
+	}
+	if store.saved[0].Type != string(TypeLike) || store.saved[1].Type != string(TypeSubscription) {
+		t.Errorf("saved = %+v", store.saved)
+	}
+}
+
+func TestServiceEmitDoesNotBlock(t *testing.T) {
+	s := NewService(&fakeStore{}, 1)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			s.Emit(Event{Type: TypeFollow, RecipientID: uuid.New()})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Emit blocked on a full queue")
+	}
+}
@@ -0,0 +1,59 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := Cursor{
+		CreatedAt: time.Date(2024, 11, 20, 10, 30, 15, 123456000, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	got, err := Decode(c.Encode())
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !got.CreatedAt.Equal(c.CreatedAt) || got.ID != c.ID {
+		t.Errorf("Decode() = %v, want %v", got, c)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "Empty", cursor: ""},
+		{name: "Not base64", cursor: "%%%"},
+		{name: "Missing separator", cursor: "MTIzNDU"},
+		{name: "Bad timestamp", cursor: base64.RawURLEncoding.EncodeToString([]byte("abc|" + uuid.NewString()))},
+		{name: "Bad id", cursor: base64.RawURLEncoding.EncodeToString([]byte("123|not-a-uuid"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.cursor); err == nil {
+				t.Errorf("Decode(%q) expected error", tt.cursor)
+			}
+		})
+	}
+}
+
+func TestOffsetRoundTrip(t *testing.T) {
+	got, err := DecodeOffset(EncodeOffset(40))
+	if err != nil || got != 40 {
+		t.Errorf("DecodeOffset() = %d, %v, want 40", got, err)
+	}
+
+	if _, err := DecodeOffset(Cursor{ID: uuid.New()}.Encode()); err == nil {
+		t.Error("DecodeOffset() expected error for a keyset cursor")
+	}
+	if _, err := DecodeOffset(base64.RawURLEncoding.EncodeToString([]byte("o|-5"))); err == nil {
+		t.Error("DecodeOffset() expected error for a negative offset")
+	}
+}
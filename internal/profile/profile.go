@@ -0,0 +1,87 @@
+// Package profile validates the public profile fields users can set.
+package profile
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxDisplayNameLength = 50
+	MaxBioLength         = 160
+	MaxLocationLength    = 30
+	MaxURLLength         = 200
+)
+
+// DisplayName trims name and checks it fits on one line. An empty name
+// clears the field.
+func DisplayName(name string) (string, error) {
+	return singleLine("Display name", name, MaxDisplayNameLength)
+}
+
+// Location trims location and checks it fits on one line. An empty location
+// clears the field.
+func Location(location string) (string, error) {
+	return singleLine("Location", location, MaxLocationLength)
+}
+
+// Bio trims bio. Unlike the other fields it may span several lines.
+func Bio(bio string) (string, error) {
+	bio = strings.TrimSpace(bio)
+	if utf8.RuneCountInString(bio) > MaxBioLength {
+		return "", errors.New("Bio is to long")
+	}
+	if strings.ContainsFunc(bio, func(r rune) bool { return r != '\n' && unicode.IsControl(r) }) {
+		return "", errors.New("Bio contains invalid characters")
+	}
+
+	return bio, nil
+}
+
+// Website checks an absolute http or https URL. An empty URL clears the
+// field.
+func Website(website string) (string, error) {
+	return webURL("Website", website)
+}
+
+// AvatarURL checks an absolute http or https URL. An empty URL clears the
+// field.
+func AvatarURL(avatarURL string) (string, error) {
+	return webURL("Avatar URL", avatarURL)
+}
+
+func singleLine(field, value string, maxLength int) (string, error) {
+	value = strings.TrimSpace(value)
+	if utf8.RuneCountInString(value) > maxLength {
+		return "", errors.New(field + " is to long")
+	}
+	if strings.ContainsFunc(value, unicode.IsControl) {
+		return "", errors.New(field + " contains invalid characters")
+	}
+
+	return value, nil
+}
+
+func webURL(field, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if len(value) > MaxURLLength {
+		return "", errors.New(field + " is to long")
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New(field + " must be an http or https URL")
+	}
+	// Credentials in a public URL are almost certainly a mistake.
+	if u.User != nil {
+		return "", errors.New(field + " must not contain credentials")
+	}
+
+	return u.String(), nil
+}
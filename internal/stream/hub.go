@@ -0,0 +1,88 @@
+// Package stream fans events out to the clients currently connected to
+// this server instance.
+package stream
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type Event struct {
+	ID int64
+	// Tx is the transaction that created the event. Stored events are
+	// ordered by Tx and then ID, which is the order they commit in.
+	Tx   int64
+	Type string
+	// UserID is the user the event is about: the author of a chirp or the
+	// recipient of a notification.
+	UserID uuid.UUID
+	// ThreadID is set for chirps that are part of a thread.
+	ThreadID uuid.NullUUID
+	Data     []byte
+}
+
+// Hub delivers published events to every matching subscription. Publishing
+// never blocks: a subscriber that falls a full buffer behind is dropped, and
+// its channel is closed so the client can reconnect and resume.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	filter func(Event) bool
+	hub    *Hub
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[*Subscription]struct{}{}}
+}
+
+// Subscribe returns a subscription receiving the events filter accepts. A
+// nil filter accepts everything.
+func (h *Hub) Subscribe(buffer int, filter func(Event) bool) *Subscription {
+	c := make(chan Event, buffer)
+	s := &Subscription{C: c, c: c, filter: filter, hub: h}
+
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+
+	return s
+}
+
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subs {
+		if s.filter != nil && !s.filter(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			h.remove(s)
+		}
+	}
+}
+
+// Close unsubscribes. It is safe to call on a subscription the hub already
+// dropped.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	if _, ok := s.hub.subs[s]; ok {
+		s.hub.remove(s)
+	}
+}
+
+func (h *Hub) remove(s *Subscription) {
+	delete(h.subs, s)
+	close(s.c)
+}
@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestHubFilter(t *testing.T) {
+	h := NewHub()
+	alice, bob := uuid.New(), uuid.New()
+
+	all := h.Subscribe(10, nil)
+	defer all.Close()
+	onlyAlice := h.Subscribe(10, func(e Event) bool {
+		return e.UserID == alice
+	})
+	defer onlyAlice.Close()
+
+	h.Publish(Event{ID: 1, UserID: alice})
+	h.Publish(Event{ID: 2, UserID: bob})
+
+	if len(all.C) != 2 {
+		t.Errorf("unfiltered subscription got %d events, want 2", len(all.C))
+	}
+	if len(onlyAlice.C) != 1 {
+		t.Fatalf("filtered subscription got %d events, want 1", len(onlyAlice.C))
+	}
+	if e := <-onlyAlice.C; e.ID != 1 {
+		t.Errorf("filtered subscription got event %d, want 1", e.ID)
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	h := NewHub()
+
+	slow := h.Subscribe(1, nil)
+	fast := h.Subscribe(10, nil)
+	defer fast.Close()
+
+	h.Publish(Event{ID: 1})
+	h.Publish(Event{ID: 2})
+
+	if e, ok := <-slow.C; !ok || e.ID != 1 {
+		t.Fatalf("slow subscriber got %v, %v; want the first event", e, ok)
+	}
+	if _, ok := <-slow.C; ok {
+		t.Fatal("slow subscriber was not dropped")
+	}
+	if len(fast.C) != 2 {
+		t.Errorf("fast subscriber got %d events, want 2", len(fast.C))
+	}
+
+	// Closing a dropped subscription must not panic.
+	slow.Close()
+}
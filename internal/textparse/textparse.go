@@ -0,0 +1,109 @@
+package textparse
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+const maxHashtagLength = 50
+
+// Hashtags returns the distinct #hashtags in body, lower-cased, in the order
+// they first appear. A tag must follow whitespace or punctuation (so URLs
+// with fragments are skipped) and contain at least one letter.
+func Hashtags(body string) []string {
+	return entities(body, '#', maxHashtagLength)
+}
+
+func entities(body string, sigil rune, maxLength int) []string {
+	result := make([]string, 0)
+	seen := make(map[string]struct{})
+	runes := []rune(body)
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != sigil || (i > 0 && isEntityRune(runes[i-1])) {
+			continue
+		}
+
+		j := i + 1
+		for j < len(runes) && isEntityRune(runes[j]) {
+			j++
+		}
+		name := string(runes[i+1 : j])
+		i = j - 1
+
+		if name == "" || len([]rune(name)) > maxLength || !strings.ContainsFunc(name, unicode.IsLetter) {
+			continue
+		}
+
+		name = strings.ToLower(name)
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		result = append(result, name)
+	}
+
+	return result
+}
+
+func isEntityRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+const (
+	minHandleLength = 3
+	maxHandleLength = 15
+)
+
+// reservedHandles could be confused with routes or staff accounts.
+var reservedHandles = map[string]struct{}{
+	"admin":   {},
+	"api":     {},
+	"app":     {},
+	"chirpy":  {},
+	"me":      {},
+	"root":    {},
+	"support": {},
+}
+
+// Mentions returns the distinct @handles in body, lower-cased, in the order
+// they first appear. Email addresses are not mentions.
+func Mentions(body string) []string {
+	result := make([]string, 0)
+	for _, handle := range entities(body, '@', maxHandleLength) {
+		if ValidateHandle(handle) == nil {
+			result = append(result, handle)
+		}
+	}
+
+	return result
+}
+
+// ValidateHandle checks a public handle: 3 to 15 ASCII letters, digits or
+// underscores, with at least one letter.
+func ValidateHandle(handle string) error {
+	if len(handle) < minHandleLength || len(handle) > maxHandleLength {
+		return errors.New("Handle must be between 3 and 15 characters")
+	}
+
+	hasLetter := false
+	for _, r := range handle {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			hasLetter = true
+		case r >= '0' && r <= '9', r == '_':
+		default:
+			return errors.New("Handle can only contain letters, digits and underscores")
+		}
+	}
+	if !hasLetter {
+		return errors.New("Handle must contain a letter")
+	}
+
+	if _, ok := reservedHandles[strings.ToLower(handle)]; ok {
+		return errors.New("Handle is reserved")
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package textparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "No tags", body: "Just a chirp", want: []string{}},
+		{name: "Single tag", body: "Loving #golang today", want: []string{"golang"}},
+		{name: "Punctuation ends a tag", body: "#Go, #rust! (#zig)", want: []string{"go", "rust", "zig"}},
+		{name: "Duplicates folded", body: "#Chirpy #chirpy #CHIRPY", want: []string{"chirpy"}},
+		{name: "Digits only", body: "We are #1", want: []string{}},
+		{name: "Inside a word", body: "see example.com/page#section", want: []string{}},
+		{name: "Underscore and unicode", body: "#go_lang #café", want: []string{"go_lang", "café"}},
+		{name: "Lone hash", body: "# not a tag", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hashtags(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Hashtags(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "No mentions", body: "Just a chirp", want: []string{}},
+		{name: "Mentions", body: "@Alice and @bob_99, hi!", want: []string{"alice", "bob_99"}},
+		{name: "Email is not a mention", body: "mail me at me@example.com", want: []string{}},
+		{name: "Too short", body: "@ab", want: []string{}},
+		{name: "Reserved", body: "@admin help", want: []string{}},
+		{name: "Duplicates folded", body: "@Alice @alice", want: []string{"alice"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mentions(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Mentions(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateHandle(t *testing.T) {
+	tests := []struct {
+		handle  string
+		wantErr bool
+	}{
+		{handle: "alice", wantErr: false},
+		{handle: "Bob_42", wantErr: false},
+		{handle: "ab", wantErr: true},
+		{handle: "averyveryverylonghandle", wantErr: true},
+		{handle: "12345", wantErr: true},
+		{handle: "bad-handle", wantErr: true},
+		{handle: "café", wantErr: true},
+		{handle: "Admin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.handle, func(t *testing.T) {
+			err := ValidateHandle(tt.handle)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateHandle(%q) error = %v, wantErr %v", tt.handle, err, tt.wantErr)
+			}
+		})
+	}
+}
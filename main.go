@@ -7,19 +7,47 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"sync/atomic"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
+	"github.com/vystepanenko/Chirpy/internal/auth"
 	"github.com/vystepanenko/Chirpy/internal/database"
+	"github.com/vystepanenko/Chirpy/internal/mailer"
+	"github.com/vystepanenko/Chirpy/internal/media"
+	"github.com/vystepanenko/Chirpy/internal/moderation"
+	"github.com/vystepanenko/Chirpy/internal/notifications"
+	"github.com/vystepanenko/Chirpy/internal/stream"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
-	secretKey      string
+	dbConn         *sql.DB
+	jwtKeys        *auth.KeyManager
+	secrets        *auth.SecretBox
+	tokenVersions  *auth.VersionCache
 	polkaKey       string
+	adminKey       string
+	wsOrigins      []string
+	wsSessions     *wsRegistry
+
+	moderation          *moderation.Pipeline
+	moderationWords     *moderation.WordList
+	moderationFileWords map[string]moderation.Action
+
+	notifications *notifications.Service
+	chirpStream   *stream.Hub
+
+	notificationStream *stream.Hub
+
+	mediaStorage media.Storage
+
+	mailer mailer.Mailer
 }
 
 func main() {
@@ -46,33 +74,212 @@ func main() {
 	if key == "" {
 		fmt.Println("Secret key not found")
 	}
+	jwtAlgorithm := auth.Algorithm(os.Getenv("JWT_ALGORITHM"))
+	if jwtAlgorithm == "" {
+		jwtAlgorithm = auth.AlgRS256
+	}
+	jwtKeyRotation := 7 * 24 * time.Hour
+	if d := os.Getenv("JWT_KEY_ROTATION"); d != "" {
+		jwtKeyRotation, err = time.ParseDuration(d)
+		if err != nil {
+			log.Fatalf("Error parsing JWT_KEY_ROTATION: %s", err)
+		}
+	}
+	// JWKS responses are cached for 5 minutes, so a new key is published
+	// well before it signs. Retired keys outlive the one hour access tokens
+	// they signed.
+	jwtKeys, err := auth.NewKeyManager(dbQueries, auth.KeyManagerConfig{
+		Algorithm:        jwtAlgorithm,
+		RotationInterval: jwtKeyRotation,
+		PublishAhead:     15 * time.Minute,
+		VerifyFor:        2 * time.Hour,
+		EncryptionKey:    []byte(key),
+	})
+	if err != nil {
+		log.Fatalf("Error creating JWT key manager: %s", err)
+	}
+	err = jwtKeys.Refresh(context.Background())
+	if err != nil {
+		log.Fatalf("Error loading JWT signing keys: %s", err)
+	}
+	secrets, err := auth.NewSecretBox([]byte(key))
+	if err != nil {
+		log.Fatalf("Error creating secret box: %s", err)
+	}
 	polkaKey := os.Getenv("POLKA_KEY")
 	if key == "" {
 		fmt.Println("Polka key not found")
 	}
+	adminKey := os.Getenv("ADMIN_KEY")
+	if adminKey == "" {
+		fmt.Println("Admin key not found, admin API is disabled")
+	}
+	// WebSocket connections from other origins are refused unless their
+	// host matches one of these patterns, e.g. "app.example.com,*.example.com".
+	wsOrigins := []string{}
+	if origins := os.Getenv("WS_ORIGINS"); origins != "" {
+		wsOrigins = strings.Split(origins, ",")
+	}
+
+	moderationFileWords, moderationRules := defaultModerationWords, []moderation.RegexRule{}
+	if path := os.Getenv("MODERATION_RULES_FILE"); path != "" {
+		moderationFileWords, moderationRules, err = moderation.LoadRulesFile(path)
+		if err != nil {
+			log.Fatalf("Error loading moderation rules: %s", err)
+		}
+	}
+	moderationWords := moderation.NewWordList(moderationFileWords)
+
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	var mediaStorage media.Storage = media.NewLocalStorage(mediaDir, "/media/")
+	if os.Getenv("MEDIA_STORAGE") == "s3" {
+		mediaStorage = media.NewS3Storage(media.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		})
+	}
+
+	// Mail carries tokens, so it is only written to the log in development.
+	// Elsewhere features that send mail are off until SMTP_ADDR is set.
+	var mail mailer.Mailer
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		mail = mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Addr:     addr,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		})
+	} else if os.Getenv("PLATFORM") == "dev" {
+		mail = mailer.LogMailer{}
+		fmt.Println("SMTP_ADDR not set, emails are written to the log")
+	} else {
+		fmt.Println("SMTP_ADDR not set, email changes are disabled")
+	}
+
 	apiCfg := &apiConfig{
 		fileserverHits: atomic.Int32{},
 		db:             dbQueries,
-		secretKey:      key,
+		dbConn:         db,
+		jwtKeys:        jwtKeys,
+		secrets:        secrets,
+		tokenVersions:  auth.NewVersionCache(dbQueries, time.Minute),
 		polkaKey:       polkaKey,
+		adminKey:       adminKey,
+		wsOrigins:      wsOrigins,
+		wsSessions:     newWSRegistry(),
+
+		moderation: moderation.NewPipeline(
+			moderationWords,
+			moderation.NewRegexFilter(moderationRules...),
+		),
+		moderationWords:     moderationWords,
+		moderationFileWords: moderationFileWords,
+
+		notifications: notifications.NewService(dbQueries, 1024),
+		chirpStream:   stream.NewHub(),
+
+		notificationStream: stream.NewHub(),
+
+		mediaStorage: mediaStorage,
+
+		mailer: mail,
 	}
 
+	err = apiCfg.reloadModerationWords()
+	if err != nil {
+		fmt.Printf("Error loading moderation words: %s\n", err)
+	}
+	go apiCfg.watchModerationWords(time.Minute)
+	go apiCfg.notifications.Run(context.Background())
+	// Revoked tokens end open websocket sessions too, not just requests.
+	apiCfg.tokenVersions.OnInvalidate(func(userId uuid.NullUUID) {
+		go apiCfg.revalidateWebSockets(userId)
+	})
+	go apiCfg.listenEvents(dbUrl)
+	go apiCfg.sweepMedia(10 * time.Minute)
+	go apiCfg.runScheduler(15 * time.Second)
+	go apiCfg.sweepSessions(time.Hour)
+	go apiCfg.jwtKeys.Run(context.Background(), time.Minute)
+
 	mux.Handle(
 		"/app/",
 		apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(".")))),
 	)
+	mux.Handle("GET /media/", middlewareNoSniff(http.StripPrefix("/media", http.FileServer(http.Dir(mediaDir)))))
 	mux.HandleFunc("GET /api/healthz", handlerReady)
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
 	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	mux.HandleFunc("GET /admin/moderation/words", apiCfg.handlerGetModerationWords)
+	mux.HandleFunc("PUT /admin/moderation/words/{word}", apiCfg.handlerSetModerationWord)
+	mux.HandleFunc("DELETE /admin/moderation/words/{word}", apiCfg.handlerDeleteModerationWord)
+	mux.HandleFunc("GET /admin/moderation/flags", apiCfg.handlerGetChirpFlags)
+	mux.HandleFunc("PUT /admin/users/{id}/ban", apiCfg.handlerBanUser)
+	mux.HandleFunc("DELETE /admin/users/{id}/ban", apiCfg.handlerUnbanUser)
+	mux.HandleFunc("POST /api/media", apiCfg.handlerUploadMedia)
 	mux.HandleFunc("POST /api/chirps", apiCfg.handlerCreateChirp)
 	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetAllChirps)
+	mux.HandleFunc("GET /api/chirps/search", apiCfg.handlerSearchChirps)
+	mux.HandleFunc("GET /api/stream", apiCfg.handlerStreamChirps)
+	mux.HandleFunc("GET /api/ws", apiCfg.handlerWebSocket)
 	mux.HandleFunc("GET /api/chirps/{chirpId}", apiCfg.handlerGetChirp)
+	mux.HandleFunc("PUT /api/chirps/{chirpId}", apiCfg.handlerUpdateChirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpId}", apiCfg.handlerDeleteChirp)
+	mux.HandleFunc("GET /api/chirps/{chirpId}/history", apiCfg.handlerGetChirpHistory)
+	mux.HandleFunc("GET /api/chirps/{chirpId}/replies", apiCfg.handlerGetChirpReplies)
+	mux.HandleFunc("GET /api/chirps/{chirpId}/thread", apiCfg.handlerGetChirpThread)
+	mux.HandleFunc("PUT /api/chirps/{chirpId}/like", apiCfg.handlerLikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpId}/like", apiCfg.handlerUnlikeChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpId}/poll/votes", apiCfg.handlerVotePoll)
+	mux.HandleFunc("POST /api/chirps/{chirpId}/bookmark", apiCfg.handlerBookmarkChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpId}/bookmark", apiCfg.handlerRemoveBookmark)
+	mux.HandleFunc("PUT /api/chirps/{chirpId}/pin", apiCfg.handlerPinChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpId}/pin", apiCfg.handlerUnpinChirp)
+	mux.HandleFunc("GET /api/bookmarks", apiCfg.handlerGetBookmarks)
+	mux.HandleFunc("POST /api/bookmarks/collections", apiCfg.handlerCreateCollection)
+	mux.HandleFunc("GET /api/bookmarks/collections", apiCfg.handlerGetCollections)
+	mux.HandleFunc("PUT /api/bookmarks/collections/{collectionId}", apiCfg.handlerRenameCollection)
+	mux.HandleFunc("DELETE /api/bookmarks/collections/{collectionId}", apiCfg.handlerDeleteCollection)
 	mux.HandleFunc("POST /api/users", apiCfg.handlerUserCreate)
 	mux.HandleFunc("POST /api/login", apiCfg.handlerUserLogin)
+	mux.HandleFunc("POST /api/login/mfa", apiCfg.handlerLoginMFA)
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefreshToken)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRefreshTokenRevoke)
+	mux.HandleFunc("GET /api/sessions", apiCfg.handlerGetSessions)
+	mux.HandleFunc("DELETE /api/sessions", apiCfg.handlerDeleteSessions)
+	mux.HandleFunc("DELETE /api/sessions/{sessionId}", apiCfg.handlerDeleteSession)
 	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUserInfo)
+	mux.HandleFunc("POST /api/users/email/confirm", apiCfg.handlerConfirmEmailChange)
+	mux.HandleFunc("PATCH /api/users/me", apiCfg.handlerUpdateProfile)
+	mux.HandleFunc("POST /api/users/me/totp", apiCfg.handlerEnrollTOTP)
+	mux.HandleFunc("POST /api/users/me/totp/verify", apiCfg.handlerVerifyTOTP)
+	mux.HandleFunc("DELETE /api/users/me/totp", apiCfg.handlerDisableTOTP)
+	mux.HandleFunc("GET /api/users/{handle}", apiCfg.handlerGetUserByHandle)
+	mux.HandleFunc("POST /api/users/{id}/follow", apiCfg.handlerFollowUser)
+	mux.HandleFunc("DELETE /api/users/{id}/follow", apiCfg.handlerUnfollowUser)
+	mux.HandleFunc("GET /api/users/{id}/followers", apiCfg.handlerGetFollowers)
+	mux.HandleFunc("GET /api/users/{id}/following", apiCfg.handlerGetFollowing)
+	mux.HandleFunc("GET /api/users/{id}/profile", apiCfg.handlerGetProfile)
+	mux.HandleFunc("POST /api/drafts", apiCfg.handlerCreateDraft)
+	mux.HandleFunc("GET /api/drafts", apiCfg.handlerGetDrafts)
+	mux.HandleFunc("GET /api/drafts/{draftId}", apiCfg.handlerGetDraft)
+	mux.HandleFunc("PUT /api/drafts/{draftId}", apiCfg.handlerUpdateDraft)
+	mux.HandleFunc("DELETE /api/drafts/{draftId}", apiCfg.handlerDeleteDraft)
+	mux.HandleFunc("POST /api/drafts/{draftId}/publish", apiCfg.handlerPublishDraft)
+	mux.HandleFunc("GET /api/timeline", apiCfg.handlerGetTimeline)
+	mux.HandleFunc("GET /api/tags/{tag}/chirps", apiCfg.handlerGetTagChirps)
+	mux.HandleFunc("GET /api/trending", apiCfg.handlerGetTrending)
+	mux.HandleFunc("GET /api/mentions", apiCfg.handlerGetMentions)
+	mux.HandleFunc("GET /api/notifications", apiCfg.handlerGetNotifications)
+	mux.HandleFunc("POST /api/notifications/{notificationId}/read", apiCfg.handlerReadNotification)
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.handlerReadAllNotifications)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerSubscription)
 
 	log.Printf("Server start on port: %s\n", port)
@@ -92,6 +299,15 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	})
 }
 
+// middlewareNoSniff stops browsers from guessing a different type for
+// uploaded files than the one they are served with.
+func middlewareNoSniff(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
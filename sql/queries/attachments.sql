@@ -0,0 +1,57 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (
+    id, user_id, storage_key, thumbnail_key, content_type, width, height, created_at
+) VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    NOW()
+)
+RETURNING *;
+
+-- name: AttachToChirp :many
+-- AttachToChirp claims unused uploads of the user for a chirp, keeping the
+-- order they were given in.
+UPDATE attachments
+set chirp_id = sqlc.arg('chirp_id'),
+    position = array_position(sqlc.arg('ids')::uuid[], id) - 1
+where id = ANY(sqlc.arg('ids')::uuid[])
+and user_id = sqlc.arg('user_id')
+and chirp_id is null
+RETURNING *;
+
+-- name: ListAttachmentsForChirps :many
+SELECT * FROM attachments a
+where a.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+order by a.chirp_id, a.position;
+
+-- name: ListUnattachedAttachments :many
+-- ListUnattachedAttachments skips uploads a draft is going to use.
+SELECT * FROM attachments a
+where a.chirp_id is null
+and a.created_at < sqlc.arg('created_before')
+and not exists (
+    SELECT 1 FROM drafts d
+    where d.user_id = a.user_id
+    and d.payload->'media_ids' @> jsonb_build_array(a.id::text)
+)
+order by a.created_at
+limit sqlc.arg('page_limit');
+
+-- name: ClaimUnattachedAttachment :one
+-- ClaimUnattachedAttachment deletes an upload unless it has been attached to
+-- a chirp or picked by a draft in the meantime. Only the caller that gets
+-- the row back may delete its blobs.
+DELETE FROM attachments a
+where a.id = $1
+and a.chirp_id is null
+and not exists (
+    SELECT 1 FROM drafts d
+    where d.user_id = a.user_id
+    and d.payload->'media_ids' @> jsonb_build_array(a.id::text)
+)
+RETURNING *;
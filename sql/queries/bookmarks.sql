@@ -0,0 +1,82 @@
+-- name: BookmarkChirp :exec
+-- BookmarkChirp adds a bookmark, or moves an existing one to another
+-- collection.
+INSERT INTO bookmarks (user_id, chirp_id, collection_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, chirp_id) DO UPDATE
+set collection_id = EXCLUDED.collection_id;
+
+-- name: RemoveBookmark :execrows
+DELETE FROM bookmarks
+where user_id = $1
+and chirp_id = $2;
+
+-- name: ListBookmarksAsc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote, b.created_at AS bookmarked_at
+FROM bookmarks b
+JOIN chirps c ON c.id = b.chirp_id
+where b.user_id = sqlc.arg('user_id')
+and (sqlc.narg('collection_id')::uuid is null or b.collection_id = sqlc.narg('collection_id'))
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (b.created_at, b.chirp_id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by b.created_at ASC, b.chirp_id ASC
+limit sqlc.arg('page_limit');
+
+-- name: ListBookmarksDesc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote, b.created_at AS bookmarked_at
+FROM bookmarks b
+JOIN chirps c ON c.id = b.chirp_id
+where b.user_id = sqlc.arg('user_id')
+and (sqlc.narg('collection_id')::uuid is null or b.collection_id = sqlc.narg('collection_id'))
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (b.created_at, b.chirp_id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by b.created_at DESC, b.chirp_id DESC
+limit sqlc.arg('page_limit');
+
+-- name: ListBookmarkedChirpIDs :many
+SELECT b.chirp_id FROM bookmarks b
+where b.user_id = sqlc.arg('user_id')
+and b.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: CreateBookmarkCollection :one
+INSERT INTO bookmark_collections (id, user_id, name, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: GetBookmarkCollection :one
+SELECT * FROM bookmark_collections
+where id = $1
+and user_id = $2;
+
+-- name: ListBookmarkCollections :many
+SELECT bc.*, count(b.chirp_id) AS bookmark_count
+FROM bookmark_collections bc
+LEFT JOIN bookmarks b ON b.collection_id = bc.id
+where bc.user_id = $1
+group by bc.id
+order by lower(bc.name);
+
+-- name: RenameBookmarkCollection :one
+UPDATE bookmark_collections
+set name = $3,
+    updated_at = NOW()
+where id = $1
+and user_id = $2
+RETURNING *;
+
+-- name: DeleteBookmarkCollection :execrows
+DELETE FROM bookmark_collections
+where id = $1
+and user_id = $2;
@@ -0,0 +1,40 @@
+-- name: CreateChirpEvent :exec
+INSERT INTO chirp_events (type, chirp_id, author_id, root_id, created_at)
+VALUES ($1, $2, $3, $4, NOW());
+
+-- name: CreateChirpDeleteEvents :exec
+-- CreateChirpDeleteEvents records delete events for a chirp and for every
+-- chirp deleting it cascades to: its replies and the rechirps of any of
+-- them. It must run before the delete, in the same transaction.
+WITH RECURSIVE doomed AS (
+    SELECT c.id, c.user_id, c.root_id
+    FROM chirps c
+    where c.id = sqlc.arg('chirp_id')
+    UNION
+    SELECT c.id, c.user_id, c.root_id
+    FROM chirps c
+    JOIN doomed d ON c.parent_id = d.id OR c.root_id = d.id OR c.rechirp_of_id = d.id
+)
+INSERT INTO chirp_events (type, chirp_id, author_id, root_id, created_at)
+SELECT sqlc.arg('type')::text, d.id, d.user_id, d.root_id, NOW()
+FROM doomed d;
+
+-- name: ListChirpEventsAfter :many
+-- ListChirpEventsAfter pages through events in commit order. Events of
+-- transactions that may still be running are left for a later call, as
+-- older transactions can still commit events that sort before them.
+SELECT * FROM chirp_events e
+where (e.tx_id, e.id) > (sqlc.arg('after_tx_id')::bigint, sqlc.arg('after_id')::bigint)
+and e.tx_id < pg_snapshot_xmin(pg_current_snapshot())::text::bigint
+and (sqlc.narg('author_id')::uuid is null or e.author_id = sqlc.narg('author_id'))
+order by e.tx_id ASC, e.id ASC
+limit sqlc.arg('page_limit');
+
+-- name: GetChirpEventHorizon :one
+-- GetChirpEventHorizon is the oldest transaction that may still add events.
+-- Every event of an older transaction can already be read.
+select pg_snapshot_xmin(pg_current_snapshot())::text::bigint;
+
+-- name: DeleteChirpEventsBefore :execrows
+DELETE FROM chirp_events
+where created_at < $1;
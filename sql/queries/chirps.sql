@@ -1,29 +1,120 @@
 -- name: CreateChirps :one
 INSERT INTO chirps (
-   id, user_id, body ,created_at, updated_at
+   id, user_id, body, parent_id, root_id, rechirp_of_id, quote_of_id, is_quote, created_at, updated_at
 ) VALUES ( 
     gen_random_uuid(),
     $1,
     $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
     NOW(),
     NOW()
 )
-RETURNING *;
+RETURNING id, user_id, body, created_at, updated_at,
+    parent_id, root_id, rechirp_of_id, quote_of_id, is_quote;
 
--- name: GetAllChirps :many
-SELECT * FROM chirps c
-order by c.created_at ASC;
+-- name: ListChirpsAsc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM chirps c
+where (sqlc.narg('author_id')::uuid is null or c.user_id = sqlc.narg('author_id'))
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (c.created_at, c.id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by c.created_at ASC, c.id ASC
+limit sqlc.arg('page_limit');
 
--- name: GetAllChirpsByAuthor :many
-SELECT * FROM chirps c
-where c.user_id = $1
-order by c.created_at ASC;
+-- name: ListChirpsDesc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM chirps c
+where (sqlc.narg('author_id')::uuid is null or c.user_id = sqlc.narg('author_id'))
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (c.created_at, c.id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by c.created_at DESC, c.id DESC
+limit sqlc.arg('page_limit');
 
 -- name: GetChirp :one
-select * from chirps c
+select c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+from chirps c
 where c.id = $1;
 
+-- name: GetChirpsByIDs :many
+select c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+from chirps c
+where c.id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: GetChirpForUpdate :one
+select c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+from chirps c
+where c.id = $1
+for update;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+set body = $1, updated_at = NOW()
+where id = $2
+RETURNING id, user_id, body, created_at, updated_at,
+    parent_id, root_id, rechirp_of_id, quote_of_id, is_quote;
+
 -- name: DeleteChirp :exec
 delete from chirps c 
 where c.id = $1
 and c.user_id = $2;
+
+-- name: ListChirpRepliesAsc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM chirps c
+where c.parent_id = sqlc.arg('parent_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (c.created_at, c.id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by c.created_at ASC, c.id ASC
+limit sqlc.arg('page_limit');
+
+-- name: ListChirpRepliesDesc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM chirps c
+where c.parent_id = sqlc.arg('parent_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (c.created_at, c.id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by c.created_at DESC, c.id DESC
+limit sqlc.arg('page_limit');
+
+-- name: GetThread :many
+WITH RECURSIVE thread AS (
+    SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+        c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote, 0 AS depth
+    FROM chirps c
+    where c.id = $1
+    UNION ALL
+    SELECT child.id, child.user_id, child.body, child.created_at, child.updated_at,
+        child.parent_id, child.root_id, child.rechirp_of_id, child.quote_of_id, child.is_quote, thread.depth + 1
+    FROM chirps child
+    JOIN thread ON child.parent_id = thread.id
+)
+SELECT t.id, t.user_id, t.body, t.created_at, t.updated_at,
+    t.parent_id, t.root_id, t.rechirp_of_id, t.quote_of_id, t.is_quote, t.depth, (
+    SELECT count(*) FROM chirps r
+    where r.parent_id = t.id
+) AS reply_count
+FROM thread t
+order by t.depth ASC, t.created_at ASC, t.id ASC;
+
+-- name: CountChirpsByAuthor :one
+select count(*) from chirps c
+where c.user_id = $1;
@@ -0,0 +1,90 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, user_id, payload, scheduled_at, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+set payload = $3,
+    scheduled_at = $4,
+    last_error = NULL,
+    attempts = 0,
+    next_attempt_at = NULL,
+    updated_at = NOW()
+where id = $1
+and user_id = $2
+RETURNING *;
+
+-- name: GetDraft :one
+SELECT * FROM drafts
+where id = $1
+and user_id = $2;
+
+-- name: GetDraftForUpdate :one
+SELECT * FROM drafts
+where id = $1
+and user_id = $2
+FOR UPDATE;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts
+where id = $1
+and user_id = $2;
+
+-- name: ListDraftsAsc :many
+SELECT * FROM drafts d
+where d.user_id = sqlc.arg('user_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (d.created_at, d.id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by d.created_at ASC, d.id ASC
+limit sqlc.arg('page_limit');
+
+-- name: ListDraftsDesc :many
+SELECT * FROM drafts d
+where d.user_id = sqlc.arg('user_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (d.created_at, d.id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by d.created_at DESC, d.id DESC
+limit sqlc.arg('page_limit');
+
+-- name: ClaimDueDraft :one
+-- ClaimDueDraft locks the next draft due for publishing. Drafts locked by
+-- another server instance are skipped, so each is published once, and so
+-- are drafts waiting to be retried.
+SELECT * FROM drafts
+where scheduled_at <= NOW()
+and (next_attempt_at is null or next_attempt_at <= NOW())
+order by scheduled_at
+limit 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: FailDraft :exec
+-- FailDraft turns a scheduled draft that could not be published back into a
+-- plain draft, keeping the reason for the user.
+UPDATE drafts
+set scheduled_at = NULL,
+    last_error = $2,
+    attempts = 0,
+    next_attempt_at = NULL,
+    updated_at = NOW()
+where id = $1;
+
+-- name: RetryDraft :one
+-- RetryDraft counts a failed attempt to publish a draft and puts the next
+-- one off, doubling the wait from a minute up to half an hour.
+UPDATE drafts
+set attempts = attempts + 1,
+    next_attempt_at = NOW() + interval '1 minute' * power(2, least(attempts, 5))
+where id = $1
+RETURNING attempts;
@@ -0,0 +1,19 @@
+-- name: CreateEmailChange :one
+-- CreateEmailChange replaces any earlier pending change of the user.
+INSERT INTO email_changes (user_id, new_email, token_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4, NOW())
+ON CONFLICT (user_id) DO UPDATE
+set new_email = EXCLUDED.new_email,
+    token_hash = EXCLUDED.token_hash,
+    expires_at = EXCLUDED.expires_at,
+    created_at = EXCLUDED.created_at
+RETURNING *;
+
+-- name: GetEmailChangeByToken :one
+SELECT * FROM email_changes
+where token_hash = $1
+and expires_at > NOW();
+
+-- name: DeleteEmailChange :exec
+DELETE FROM email_changes
+where user_id = $1;
@@ -0,0 +1,74 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (
+    follower_id, followee_id, created_at
+) VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+delete from follows f
+where f.follower_id = $1
+and f.followee_id = $2;
+
+-- name: CountFollowers :one
+select count(*) from follows f
+where f.followee_id = $1;
+
+-- name: CountFollowing :one
+select count(*) from follows f
+where f.follower_id = $1;
+
+-- name: ListFollowersAsc :many
+select u.id, u.handle, u.display_name, u.avatar_url, u.created_at, f.created_at as followed_at
+from follows f
+join users u on u.id = f.follower_id
+where f.followee_id = sqlc.arg('user_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (f.created_at, f.follower_id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by f.created_at ASC, f.follower_id ASC
+limit sqlc.arg('page_limit');
+
+-- name: ListFollowersDesc :many
+select u.id, u.handle, u.display_name, u.avatar_url, u.created_at, f.created_at as followed_at
+from follows f
+join users u on u.id = f.follower_id
+where f.followee_id = sqlc.arg('user_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (f.created_at, f.follower_id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by f.created_at DESC, f.follower_id DESC
+limit sqlc.arg('page_limit');
+
+-- name: ListFollowingAsc :many
+select u.id, u.handle, u.display_name, u.avatar_url, u.created_at, f.created_at as followed_at
+from follows f
+join users u on u.id = f.followee_id
+where f.follower_id = sqlc.arg('user_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (f.created_at, f.followee_id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by f.created_at ASC, f.followee_id ASC
+limit sqlc.arg('page_limit');
+
+-- name: ListFollowingDesc :many
+select u.id, u.handle, u.display_name, u.avatar_url, u.created_at, f.created_at as followed_at
+from follows f
+join users u on u.id = f.followee_id
+where f.follower_id = sqlc.arg('user_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (f.created_at, f.followee_id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by f.created_at DESC, f.followee_id DESC
+limit sqlc.arg('page_limit');
+
+-- name: ListFolloweeIDs :many
+SELECT followee_id FROM follows
+where follower_id = $1;
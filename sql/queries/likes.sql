@@ -0,0 +1,25 @@
+-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (
+    chirp_id, user_id, created_at
+) VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+delete from chirp_likes cl
+where cl.chirp_id = $1
+and cl.user_id = $2;
+
+-- name: CountLikesForChirps :many
+select cl.chirp_id, count(*) as like_count
+from chirp_likes cl
+where cl.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+group by cl.chirp_id;
+
+-- name: ListLikedChirpIDs :many
+select cl.chirp_id from chirp_likes cl
+where cl.user_id = sqlc.arg('user_id')
+and cl.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
@@ -0,0 +1,40 @@
+-- name: CreateMentions :many
+INSERT INTO mentions (
+    chirp_id, user_id, created_at
+)
+SELECT c.id, u.id, c.created_at
+FROM chirps c, users u
+where c.id = sqlc.arg('chirp_id')
+and lower(u.handle) = ANY(sqlc.arg('handles')::text[])
+ON CONFLICT DO NOTHING
+RETURNING user_id;
+
+-- name: ClearChirpMentions :exec
+delete from mentions m
+where m.chirp_id = $1;
+
+-- name: ListMentionsAsc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM mentions m
+JOIN chirps c ON c.id = m.chirp_id
+where m.user_id = sqlc.arg('user_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (m.created_at, m.chirp_id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by m.created_at ASC, m.chirp_id ASC
+limit sqlc.arg('page_limit');
+
+-- name: ListMentionsDesc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM mentions m
+JOIN chirps c ON c.id = m.chirp_id
+where m.user_id = sqlc.arg('user_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (m.created_at, m.chirp_id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by m.created_at DESC, m.chirp_id DESC
+limit sqlc.arg('page_limit');
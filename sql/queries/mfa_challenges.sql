@@ -0,0 +1,23 @@
+-- name: CreateMFAChallenge :exec
+INSERT INTO mfa_challenges (token_hash, user_id, device_label, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetMFAChallengeForUpdate :one
+SELECT * FROM mfa_challenges
+where token_hash = $1
+and expires_at > NOW()
+FOR UPDATE;
+
+-- name: RecordMFAChallengeFailure :one
+UPDATE mfa_challenges
+set attempts = attempts + 1
+where token_hash = $1
+RETURNING attempts;
+
+-- name: DeleteMFAChallenge :exec
+DELETE FROM mfa_challenges
+where token_hash = $1;
+
+-- name: DeleteExpiredMFAChallenges :execrows
+DELETE FROM mfa_challenges
+where expires_at < NOW();
@@ -0,0 +1,35 @@
+-- name: ListModerationWords :many
+SELECT * FROM moderation_words mw
+order by mw.word ASC;
+
+-- name: UpsertModerationWord :one
+INSERT INTO moderation_words (
+    word, action, created_at, updated_at
+) VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW()
+)
+ON CONFLICT (word) DO UPDATE
+set action = EXCLUDED.action, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteModerationWord :exec
+delete from moderation_words mw
+where mw.word = $1;
+
+-- name: CreateChirpFlag :exec
+INSERT INTO chirp_flags (
+    id, chirp_id, reasons, created_at
+) VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    NOW()
+);
+
+-- name: ListChirpFlags :many
+SELECT * FROM chirp_flags cf
+order by cf.created_at DESC
+limit $1;
@@ -0,0 +1,54 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (
+    id, user_id, actor_id, type, chirp_id, created_at
+) VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW()
+)
+RETURNING *;
+
+-- name: ListNotificationsAsc :many
+SELECT * FROM notifications n
+where n.user_id = sqlc.arg('user_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (n.created_at, n.id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by n.created_at ASC, n.id ASC
+limit sqlc.arg('page_limit');
+
+-- name: ListNotificationsDesc :many
+SELECT * FROM notifications n
+where n.user_id = sqlc.arg('user_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (n.created_at, n.id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by n.created_at DESC, n.id DESC
+limit sqlc.arg('page_limit');
+
+-- name: CountUnreadNotifications :one
+select count(*) from notifications n
+where n.user_id = $1
+and n.read_at is null;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+set read_at = NOW()
+where id = $1
+and user_id = $2
+and read_at is null;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+set read_at = NOW()
+where user_id = $1
+and read_at is null;
+
+-- name: GetNotification :one
+SELECT * FROM notifications
+where id = $1;
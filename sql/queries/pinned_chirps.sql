@@ -0,0 +1,22 @@
+-- name: PinChirp :execrows
+INSERT INTO pinned_chirps (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pinned_chirps
+where user_id = $1
+and chirp_id = $2;
+
+-- name: CountPinnedChirps :one
+SELECT count(*) FROM pinned_chirps
+where user_id = $1;
+
+-- name: ListPinnedChirps :many
+-- ListPinnedChirps returns the most recently pinned chirps first.
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM pinned_chirps p
+JOIN chirps c ON c.id = p.chirp_id
+where p.user_id = $1
+order by p.created_at DESC, p.chirp_id DESC;
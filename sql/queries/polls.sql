@@ -0,0 +1,42 @@
+-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, closes_at, created_at)
+VALUES (
+    sqlc.arg('chirp_id'),
+    NOW() + make_interval(secs => sqlc.arg('duration_seconds')::float8),
+    NOW()
+);
+
+-- name: CreatePollOptions :exec
+INSERT INTO poll_options (id, chirp_id, position, text)
+SELECT gen_random_uuid(), sqlc.arg('chirp_id'), o.ord - 1, o.text
+FROM unnest(sqlc.arg('texts')::text[]) WITH ORDINALITY AS o(text, ord);
+
+-- name: ListPollsForChirps :many
+SELECT p.chirp_id, p.closes_at, (p.closes_at <= NOW())::boolean AS closed
+FROM polls p
+where p.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: ListPollOptionsForChirps :many
+SELECT o.id, o.chirp_id, o.text, count(v.user_id) AS votes
+FROM poll_options o
+LEFT JOIN poll_votes v ON v.option_id = o.id
+where o.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+group by o.id
+order by o.chirp_id, o.position;
+
+-- name: ListPollVotesOfUser :many
+SELECT v.chirp_id, v.option_id FROM poll_votes v
+where v.user_id = sqlc.arg('user_id')
+and v.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: VotePoll :execrows
+-- VotePoll records a vote for an option of a poll that is still open. No
+-- row is inserted if the user has already voted.
+INSERT INTO poll_votes (chirp_id, user_id, option_id, created_at)
+SELECT o.chirp_id, sqlc.arg('user_id'), o.id, NOW()
+FROM poll_options o
+JOIN polls p ON p.chirp_id = o.chirp_id
+where o.id = sqlc.arg('option_id')
+and o.chirp_id = sqlc.arg('chirp_id')
+and p.closes_at > NOW()
+ON CONFLICT DO NOTHING;
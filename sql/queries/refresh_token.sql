@@ -1,9 +1,10 @@
 -- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens (
-    token, user_id, expires_at, created_at, updated_at
+    token_hash, user_id, session_id, expires_at, created_at, updated_at
 ) VALUES (
     $1,
     $2,
+    $3,
     NOW() + INTERVAL '60 days',
     NOW(),
     NOW()
@@ -11,12 +12,18 @@ INSERT INTO refresh_tokens (
     RETURNING *;
 
 -- name: GetRefreshToken :one
+-- GetRefreshToken also returns tokens that were already used, so that a
+-- replayed token can be recognised.
 select * from refresh_tokens rt 
-where rt."token" = $1
+where rt.token_hash = $1
 and rt.revoked_at is null 
 and rt.expires_at > now();
 
--- name: RevokeRefreshToken :exec
-update refresh_tokens 
-set revoked_at = now(), updated_at = now()
-where "token" =$1;
+-- name: UseRefreshToken :execrows
+-- UseRefreshToken marks a token as used. Of two requests racing with the
+-- same token only one sees a row affected.
+update refresh_tokens
+set used_at = now(), updated_at = now()
+where token_hash = $1
+and used_at is null
+and revoked_at is null;
@@ -0,0 +1,15 @@
+-- name: SearchChirps :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote,
+    ts_rank_cd(c.search_document, q.query)::real AS rank,
+    ts_headline(
+        'english', c.body, q.query,
+        'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', MaxFragments=2, MinWords=5, MaxWords=20'
+    ) AS snippet
+FROM chirps c
+CROSS JOIN websearch_to_tsquery('english', sqlc.arg('query')) AS q(query)
+where c.search_document @@ q.query
+and (sqlc.narg('author_id')::uuid is null or c.user_id = sqlc.narg('author_id'))
+order by rank DESC, c.created_at DESC, c.id DESC
+limit sqlc.arg('page_limit')
+offset sqlc.arg('page_offset');
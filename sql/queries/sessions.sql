@@ -0,0 +1,47 @@
+-- name: CreateSession :one
+INSERT INTO sessions (
+    id, user_id, user_agent, ip, device_label, created_at, last_used_at, expires_at
+) VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW(),
+    NOW(),
+    NOW() + INTERVAL '60 days'
+)
+RETURNING *;
+
+-- name: TouchSession :exec
+-- TouchSession records a refresh. The session lives as long as the refresh
+-- token issued with it.
+UPDATE sessions
+set user_agent = $2,
+    ip = $3,
+    last_used_at = NOW(),
+    expires_at = NOW() + INTERVAL '60 days'
+where id = $1;
+
+-- name: ListSessions :many
+SELECT * FROM sessions
+where user_id = $1
+and expires_at > NOW()
+order by last_used_at DESC, id DESC;
+
+-- name: ListSessionIDs :many
+SELECT id FROM sessions
+where user_id = $1;
+
+-- name: DeleteSession :execrows
+DELETE FROM sessions
+where id = $1
+and user_id = $2;
+
+-- name: DeleteUserSessions :execrows
+DELETE FROM sessions
+where user_id = $1;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions
+where expires_at < NOW();
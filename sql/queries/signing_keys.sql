@@ -0,0 +1,12 @@
+-- name: ListSigningKeys :many
+SELECT * FROM signing_keys
+order by created_at ASC;
+
+-- name: CreateSigningKey :one
+INSERT INTO signing_keys (id, algorithm, private_key, created_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: DeleteSigningKey :exec
+DELETE FROM signing_keys
+where id = $1;
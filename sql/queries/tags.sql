@@ -0,0 +1,62 @@
+-- name: TagChirp :exec
+WITH new_tags AS (
+    INSERT INTO tags (id, name, created_at)
+    SELECT gen_random_uuid(), n.name, NOW()
+    FROM unnest(sqlc.arg('names')::text[]) AS n(name)
+    ON CONFLICT (name) DO NOTHING
+    RETURNING id
+), chirp_tag_ids AS (
+    SELECT id FROM new_tags
+    UNION
+    SELECT t.id FROM tags t
+    where t.name = ANY(sqlc.arg('names')::text[])
+)
+INSERT INTO chirp_tags (chirp_id, tag_id, created_at)
+SELECT c.id, cti.id, c.created_at
+FROM chirps c, chirp_tag_ids cti
+where c.id = sqlc.arg('chirp_id')
+ON CONFLICT DO NOTHING;
+
+-- name: ClearChirpTags :exec
+delete from chirp_tags ct
+where ct.chirp_id = $1;
+
+-- name: ListTagChirpsAsc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM chirp_tags ct
+JOIN tags t ON t.id = ct.tag_id
+JOIN chirps c ON c.id = ct.chirp_id
+where t.name = sqlc.arg('name')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (ct.created_at, ct.chirp_id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by ct.created_at ASC, ct.chirp_id ASC
+limit sqlc.arg('page_limit');
+
+-- name: ListTagChirpsDesc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM chirp_tags ct
+JOIN tags t ON t.id = ct.tag_id
+JOIN chirps c ON c.id = ct.chirp_id
+where t.name = sqlc.arg('name')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (ct.created_at, ct.chirp_id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by ct.created_at DESC, ct.chirp_id DESC
+limit sqlc.arg('page_limit');
+
+-- name: TrendingTags :many
+-- Every use counts for exp(-age / decay), so a tag used a lot an hour ago
+-- ranks below one picking up right now.
+SELECT t.name, count(*) AS uses,
+    sum(exp(-extract(epoch FROM NOW() - ct.created_at) / sqlc.arg('decay_seconds')::float8))::float8 AS score
+FROM chirp_tags ct
+JOIN tags t ON t.id = ct.tag_id
+where ct.created_at > NOW() - make_interval(secs => sqlc.arg('window_seconds')::float8)
+group by t.name
+order by score DESC, uses DESC, t.name ASC
+limit sqlc.arg('page_limit');
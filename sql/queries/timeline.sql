@@ -0,0 +1,55 @@
+-- name: FanOutChirp :exec
+INSERT INTO timeline_entries (
+    user_id, chirp_id, author_id, created_at
+)
+SELECT f.follower_id, c.id, c.user_id, c.created_at
+FROM chirps c
+JOIN follows f ON f.followee_id = c.user_id
+where c.id = $1
+UNION ALL
+SELECT c.user_id, c.id, c.user_id, c.created_at
+FROM chirps c
+where c.id = $1
+ON CONFLICT DO NOTHING;
+
+-- name: BackfillTimeline :exec
+INSERT INTO timeline_entries (
+    user_id, chirp_id, author_id, created_at
+)
+SELECT sqlc.arg('follower_id')::uuid, c.id, c.user_id, c.created_at
+FROM chirps c
+where c.user_id = sqlc.arg('followee_id')
+order by c.created_at DESC
+limit 200
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveAuthorFromTimeline :exec
+delete from timeline_entries te
+where te.user_id = $1
+and te.author_id = $2;
+
+-- name: ListTimelineAsc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM timeline_entries te
+JOIN chirps c ON c.id = te.chirp_id
+where te.user_id = sqlc.arg('user_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (te.created_at, te.chirp_id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by te.created_at ASC, te.chirp_id ASC
+limit sqlc.arg('page_limit');
+
+-- name: ListTimelineDesc :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at,
+    c.parent_id, c.root_id, c.rechirp_of_id, c.quote_of_id, c.is_quote
+FROM timeline_entries te
+JOIN chirps c ON c.id = te.chirp_id
+where te.user_id = sqlc.arg('user_id')
+and (
+    sqlc.narg('cursor_created_at')::timestamp is null
+    or (te.created_at, te.chirp_id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+order by te.created_at DESC, te.chirp_id DESC
+limit sqlc.arg('page_limit');
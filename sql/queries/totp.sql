@@ -0,0 +1,71 @@
+-- name: CreateTOTPSecret :execrows
+-- CreateTOTPSecret starts an enrollment, or restarts one that was never
+-- verified. It leaves an active one alone.
+INSERT INTO user_totp (user_id, secret, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id) DO UPDATE
+set secret = excluded.secret,
+    last_step = 0,
+    failed_attempts = 0,
+    locked_until = NULL,
+    created_at = excluded.created_at
+where user_totp.enabled_at is null;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+where user_id = $1;
+
+-- name: GetUserTOTPForUpdate :one
+SELECT *, coalesce(locked_until > NOW(), false)::boolean AS locked FROM user_totp
+where user_id = $1
+FOR UPDATE;
+
+-- name: EnableTOTP :execrows
+UPDATE user_totp
+set enabled_at = NOW(), last_step = $2
+where user_id = $1
+and enabled_at is null;
+
+-- name: UseTOTPStep :execrows
+-- UseTOTPStep accepts a code of a time step after the last accepted one.
+UPDATE user_totp
+set last_step = $2
+where user_id = $1
+and last_step < $2;
+
+-- name: RecordTOTPFailure :exec
+-- RecordTOTPFailure counts a wrong code. Every tenth in a row locks the
+-- account out of the second login step for 15 minutes.
+UPDATE user_totp
+set failed_attempts = failed_attempts + 1,
+    locked_until = CASE
+        WHEN (failed_attempts + 1) % 10 = 0 THEN NOW() + INTERVAL '15 minutes'
+        ELSE locked_until
+    END
+where user_id = $1;
+
+-- name: ResetTOTPFailures :exec
+UPDATE user_totp
+set failed_attempts = 0, locked_until = NULL
+where user_id = $1;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+where user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (user_id, code_hash, created_at)
+VALUES ($1, $2, NOW());
+
+-- name: UseRecoveryCode :execrows
+DELETE FROM recovery_codes
+where user_id = $1
+and code_hash = $2;
+
+-- name: CountRecoveryCodes :one
+SELECT count(*) FROM recovery_codes
+where user_id = $1;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+where user_id = $1;
@@ -1,10 +1,11 @@
 -- name: CreateUser :one
 INSERT INTO users (
-   id, email, hashed_password, created_at, updated_at
+   id, email, hashed_password, handle, created_at, updated_at
 ) VALUES ( 
     gen_random_uuid(),
     $1,
     $2,
+    $3,
     NOW(),
     NOW()
 ) returning *;
@@ -16,14 +17,80 @@ DELETE FROM USERS;
 SELECT * FROM users u
 where u.email = $1; 
 
--- name: UpdateUserInfo :one
+-- name: UpdateUserPassword :one
+-- UpdateUserPassword also bumps the token version, so access tokens issued
+-- with the old password stop working.
 UPDATE users
-set email = $1, hashed_password = $2
-where id = $3
+set hashed_password = $1, token_version = token_version + 1, updated_at = NOW()
+where id = $2
+RETURNING *;
+
+-- name: UpdateUserEmail :one
+UPDATE users
+set email = $1, updated_at = NOW()
+where id = $2
 RETURNING *;
 
--- name: UpdateChirpyRed :one
+-- name: GetUser :one
+SELECT * FROM users u
+where u.id = $1;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users u
+where lower(u.handle) = lower(sqlc.arg('handle'));
+
+-- name: UpdateUserHandle :one
 UPDATE users
-set is_chirpy_red = $1
+set handle = $1, updated_at = NOW()
 where id = $2
 RETURNING *;
+
+-- name: GetUserForUpdate :one
+SELECT * FROM users u
+where u.id = $1
+FOR UPDATE;
+
+-- name: UpdateUserProfile :one
+-- UpdateUserProfile sets the fields that are not null and leaves the rest
+-- untouched.
+UPDATE users
+set handle = coalesce(sqlc.narg('handle'), handle),
+    display_name = coalesce(sqlc.narg('display_name'), display_name),
+    bio = coalesce(sqlc.narg('bio'), bio),
+    avatar_url = coalesce(sqlc.narg('avatar_url'), avatar_url),
+    location = coalesce(sqlc.narg('location'), location),
+    website = coalesce(sqlc.narg('website'), website),
+    updated_at = NOW()
+where id = sqlc.arg('id')
+RETURNING *;
+
+-- name: GetUserTokenVersion :one
+-- GetUserTokenVersion finds no rows for banned users.
+SELECT token_version FROM users u
+where u.id = $1
+and u.banned_at is null;
+
+-- name: BumpTokenVersion :one
+UPDATE users
+set token_version = token_version + 1
+where id = $1
+RETURNING token_version;
+
+-- name: BanUser :execrows
+UPDATE users
+set banned_at = NOW(), token_version = token_version + 1
+where id = $1
+and banned_at is null;
+
+-- name: UnbanUser :execrows
+UPDATE users
+set banned_at = NULL
+where id = $1
+and banned_at is not null;
+
+-- name: UpgradeToChirpyRed :execrows
+-- UpgradeToChirpyRed finds no rows for users that are Red already.
+UPDATE users
+set is_chirpy_red = true
+where id = $1
+and is_chirpy_red = false;
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD column parent_id UUID,
+ADD column root_id UUID,
+ADD CONSTRAINT chirps_parent_foreign FOREIGN KEY (parent_id) REFERENCES chirps (id) ON DELETE CASCADE,
+ADD CONSTRAINT chirps_root_foreign FOREIGN KEY (root_id) REFERENCES chirps (id) ON DELETE CASCADE;
+
+CREATE INDEX chirps_parent_id_created_at_id_idx ON chirps (parent_id, created_at, id);
+CREATE INDEX chirps_root_id_idx ON chirps (root_id);
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN root_id,
+DROP COLUMN parent_id;
@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE follows (
+    follower_id UUID NOT NULL,
+    followee_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (follower_id, followee_id),
+    CONSTRAINT follows_follower_foreign FOREIGN KEY (follower_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT follows_followee_foreign FOREIGN KEY (followee_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT follows_not_self CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX follows_follower_id_created_at_idx ON follows (follower_id, created_at, followee_id);
+CREATE INDEX follows_followee_id_created_at_idx ON follows (followee_id, created_at, follower_id);
+
+-- Home timelines are materialised on write: every chirp is copied into the
+-- timeline of its author and of each follower, so reading a timeline is a
+-- single index range scan no matter how many accounts a user follows.
+CREATE TABLE timeline_entries (
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    author_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id),
+    CONSTRAINT timeline_entries_user_foreign FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT timeline_entries_chirp_foreign FOREIGN KEY (chirp_id) REFERENCES chirps (id) ON DELETE CASCADE
+);
+
+CREATE INDEX timeline_entries_user_id_created_at_idx ON timeline_entries (user_id, created_at, chirp_id);
+CREATE INDEX timeline_entries_user_id_author_id_idx ON timeline_entries (user_id, author_id);
+CREATE INDEX timeline_entries_chirp_id_idx ON timeline_entries (chirp_id);
+
+-- +goose Down
+DROP TABLE timeline_entries;
+DROP TABLE follows;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Rechirps carry no body of their own, so bodies can no longer be unique.
+ALTER TABLE chirps DROP CONSTRAINT chirps_body_key;
+
+-- A rechirp goes away with its original, a quote keeps its own body and
+-- only loses the reference (is_quote stays set as a tombstone).
+ALTER TABLE chirps
+ADD column rechirp_of_id UUID,
+ADD column quote_of_id UUID,
+ADD column is_quote bool DEFAULT false NOT NULL,
+ADD CONSTRAINT chirps_rechirp_of_foreign FOREIGN KEY (rechirp_of_id) REFERENCES chirps (id) ON DELETE CASCADE,
+ADD CONSTRAINT chirps_quote_of_foreign FOREIGN KEY (quote_of_id) REFERENCES chirps (id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX chirps_user_id_rechirp_of_id_idx ON chirps (user_id, rechirp_of_id)
+WHERE rechirp_of_id IS NOT NULL;
+CREATE INDEX chirps_quote_of_id_idx ON chirps (quote_of_id);
+
+-- +goose Down
+DELETE FROM chirps WHERE rechirp_of_id IS NOT NULL;
+
+ALTER TABLE chirps
+DROP COLUMN is_quote,
+DROP COLUMN quote_of_id,
+DROP COLUMN rechirp_of_id;
+
+ALTER TABLE chirps ADD CONSTRAINT chirps_body_key UNIQUE (body);
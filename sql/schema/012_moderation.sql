@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE moderation_words (
+    word TEXT PRIMARY KEY,
+    action TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT moderation_words_action_check CHECK (action IN ('allow', 'mask', 'flag', 'reject'))
+);
+
+CREATE TABLE chirp_flags (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    chirp_id UUID NOT NULL,
+    reasons TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT chirp_flags_chirp_foreign FOREIGN KEY (chirp_id) REFERENCES chirps (id) ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_flags_created_at_idx ON chirp_flags (created_at);
+CREATE INDEX chirp_flags_chirp_id_idx ON chirp_flags (chirp_id);
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE moderation_words;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- The search document lives next to the chirp rather than on it, so regular
+-- chirp reads do not carry the tsvector around.
+CREATE TABLE chirp_search (
+    chirp_id UUID PRIMARY KEY,
+    document tsvector NOT NULL,
+    CONSTRAINT chirp_search_chirp_foreign FOREIGN KEY (chirp_id) REFERENCES chirps (id) ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_search_document_idx ON chirp_search USING GIN (document);
+
+-- +goose StatementBegin
+CREATE FUNCTION chirp_search_sync() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO chirp_search (chirp_id, document)
+    VALUES (NEW.id, to_tsvector('english', NEW.body))
+    ON CONFLICT (chirp_id) DO UPDATE SET document = EXCLUDED.document;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirp_search_sync
+AFTER INSERT OR UPDATE OF body ON chirps
+FOR EACH ROW EXECUTE FUNCTION chirp_search_sync();
+
+INSERT INTO chirp_search (chirp_id, document)
+SELECT c.id, to_tsvector('english', c.body) FROM chirps c;
+
+-- +goose Down
+DROP TRIGGER chirp_search_sync ON chirps;
+DROP FUNCTION chirp_search_sync();
+DROP TABLE chirp_search;
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE users
+ADD column handle TEXT;
+
+CREATE UNIQUE INDEX users_handle_lower_idx ON users (lower(handle));
+
+CREATE TABLE mentions (
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, user_id),
+    CONSTRAINT mentions_chirp_foreign FOREIGN KEY (chirp_id) REFERENCES chirps (id) ON DELETE CASCADE,
+    CONSTRAINT mentions_user_foreign FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX mentions_user_id_created_at_idx ON mentions (user_id, created_at, chirp_id);
+
+-- +goose Down
+DROP TABLE mentions;
+DROP INDEX users_handle_lower_idx;
+ALTER TABLE users DROP COLUMN handle;
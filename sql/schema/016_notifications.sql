@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    actor_id UUID,
+    type TEXT NOT NULL,
+    chirp_id UUID,
+    created_at TIMESTAMP NOT NULL,
+    read_at TIMESTAMP,
+    CONSTRAINT notifications_user_foreign FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT notifications_actor_foreign FOREIGN KEY (actor_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT notifications_chirp_foreign FOREIGN KEY (chirp_id) REFERENCES chirps (id) ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_user_id_created_at_idx ON notifications (user_id, created_at, id);
+CREATE INDEX notifications_user_id_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
+CREATE INDEX notifications_actor_id_idx ON notifications (actor_id);
+CREATE INDEX notifications_chirp_id_idx ON notifications (chirp_id);
+
+-- +goose Down
+DROP TABLE notifications;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE FUNCTION notifications_notify() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('notifications', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER notifications_notify
+AFTER INSERT ON notifications
+FOR EACH ROW EXECUTE FUNCTION notifications_notify();
+
+-- +goose Down
+DROP TRIGGER notifications_notify ON notifications;
+DROP FUNCTION notifications_notify;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE attachments (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    chirp_id UUID,
+    position INTEGER NOT NULL DEFAULT 0,
+    storage_key TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT attachments_user_foreign FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    -- Deleting a chirp detaches its attachments, and the sweeper removes
+    -- their blobs along with uploads that were never used.
+    CONSTRAINT attachments_chirp_foreign FOREIGN KEY (chirp_id) REFERENCES chirps (id) ON DELETE SET NULL
+);
+
+CREATE INDEX attachments_chirp_id_idx ON attachments (chirp_id, position);
+CREATE INDEX attachments_unattached_idx ON attachments (created_at) WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP TABLE attachments;
@@ -0,0 +1,36 @@
+-- +goose Up
+CREATE TABLE polls (
+    chirp_id UUID PRIMARY KEY,
+    closes_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT polls_chirp_foreign FOREIGN KEY (chirp_id) REFERENCES chirps (id) ON DELETE CASCADE
+);
+
+CREATE TABLE poll_options (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    chirp_id UUID NOT NULL,
+    position INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    CONSTRAINT poll_options_poll_foreign FOREIGN KEY (chirp_id) REFERENCES polls (chirp_id) ON DELETE CASCADE,
+    CONSTRAINT poll_options_chirp_id_position_key UNIQUE (chirp_id, position)
+);
+
+-- The primary key is what makes a vote count once per user.
+CREATE TABLE poll_votes (
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    option_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, user_id),
+    CONSTRAINT poll_votes_poll_foreign FOREIGN KEY (chirp_id) REFERENCES polls (chirp_id) ON DELETE CASCADE,
+    CONSTRAINT poll_votes_user_foreign FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT poll_votes_option_foreign FOREIGN KEY (option_id) REFERENCES poll_options (id) ON DELETE CASCADE
+);
+
+CREATE INDEX poll_votes_option_id_idx ON poll_votes (option_id);
+CREATE INDEX poll_votes_user_id_idx ON poll_votes (user_id);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE drafts (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    -- payload is the chirp request the draft will be published with.
+    payload JSONB NOT NULL,
+    scheduled_at TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT drafts_user_foreign FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX drafts_user_id_created_at_idx ON drafts (user_id, created_at, id);
+CREATE INDEX drafts_scheduled_at_idx ON drafts (scheduled_at) WHERE scheduled_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE drafts;
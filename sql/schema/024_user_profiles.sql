@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE users
+ADD column display_name TEXT NOT NULL DEFAULT '',
+ADD column bio TEXT NOT NULL DEFAULT '',
+ADD column avatar_url TEXT NOT NULL DEFAULT '',
+ADD column location TEXT NOT NULL DEFAULT '',
+ADD column website TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN display_name,
+DROP COLUMN bio,
+DROP COLUMN avatar_url,
+DROP COLUMN location,
+DROP COLUMN website;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- A user has at most one pending email change. The new address is only
+-- written to users once the token sent to it comes back.
+CREATE TABLE email_changes (
+    user_id UUID PRIMARY KEY,
+    new_email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT email_changes_user_foreign FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE email_changes;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- Refresh tokens are stored as the hex SHA-256 of the token. Every refresh
+-- replaces the token with a new one in the same family; a token that comes
+-- back after it was used gives the whole family away.
+ALTER TABLE refresh_tokens
+RENAME COLUMN token TO token_hash;
+
+UPDATE refresh_tokens
+set token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+ALTER TABLE refresh_tokens
+ADD column family_id UUID NOT NULL DEFAULT gen_random_uuid(),
+ADD column used_at TIMESTAMP;
+
+ALTER TABLE refresh_tokens
+ALTER column family_id DROP DEFAULT;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
+
+-- +goose Down
+-- The original tokens can not be recovered, so every session ends.
+DELETE FROM refresh_tokens;
+
+DROP INDEX refresh_tokens_expires_at_idx;
+DROP INDEX refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN used_at,
+DROP COLUMN family_id;
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token_hash TO token;
@@ -0,0 +1,45 @@
+-- +goose Up
+-- A session is one login. Its refresh tokens are the token family that
+-- rotation creates, and ending the session deletes them.
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    user_agent TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    device_label TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    CONSTRAINT sessions_user_foreign FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX sessions_user_id_last_used_at_idx ON sessions (user_id, last_used_at);
+CREATE INDEX sessions_expires_at_idx ON sessions (expires_at);
+
+INSERT INTO sessions (id, user_id, user_agent, ip, device_label, created_at, last_used_at, expires_at)
+SELECT family_id, user_id, '', '', '', min(created_at), max(updated_at), max(expires_at)
+FROM refresh_tokens
+where revoked_at is null
+group by family_id, user_id;
+
+DELETE FROM refresh_tokens
+where family_id NOT IN (SELECT id FROM sessions);
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN family_id TO session_id;
+
+ALTER INDEX refresh_tokens_family_id_idx RENAME TO refresh_tokens_session_id_idx;
+
+ALTER TABLE refresh_tokens
+ADD CONSTRAINT refresh_tokens_session_foreign FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP CONSTRAINT refresh_tokens_session_foreign;
+
+ALTER INDEX refresh_tokens_session_id_idx RENAME TO refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN session_id TO family_id;
+
+DROP TABLE sessions;
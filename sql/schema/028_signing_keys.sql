@@ -0,0 +1,11 @@
+-- +goose Up
+-- Private keys are stored encrypted with a key derived from SECRET_KEY.
+CREATE TABLE signing_keys (
+    id TEXT PRIMARY KEY,
+    algorithm TEXT NOT NULL,
+    private_key BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE signing_keys;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- Access tokens carry the token version of their user and stop being valid
+-- when it is bumped. Banned users have no valid tokens at all.
+ALTER TABLE users
+ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN banned_at TIMESTAMP;
+
+-- +goose StatementBegin
+CREATE FUNCTION token_versions_notify() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('token_versions', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER token_versions_notify
+AFTER UPDATE OF token_version, banned_at ON users
+FOR EACH ROW EXECUTE FUNCTION token_versions_notify();
+
+-- +goose Down
+DROP TRIGGER token_versions_notify ON users;
+DROP FUNCTION token_versions_notify;
+
+ALTER TABLE users
+DROP COLUMN banned_at,
+DROP COLUMN token_version;
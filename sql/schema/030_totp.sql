@@ -0,0 +1,40 @@
+-- +goose Up
+-- A user has a row while enrolled in two-factor authentication. It is
+-- active once enabled_at is set. The secret is encrypted with SECRET_KEY.
+CREATE TABLE user_totp (
+    user_id UUID PRIMARY KEY,
+    secret BYTEA NOT NULL,
+    enabled_at TIMESTAMP,
+    -- The time step of the last accepted code, so a code works only once.
+    last_step BIGINT NOT NULL DEFAULT 0,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT user_totp_user_foreign FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes (
+    user_id UUID NOT NULL,
+    code_hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, code_hash),
+    CONSTRAINT recovery_codes_user_foreign FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- A challenge is issued when the password was right and a code is still
+-- needed to finish logging in.
+CREATE TABLE mfa_challenges (
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL,
+    device_label TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    CONSTRAINT mfa_challenges_user_foreign FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX mfa_challenges_expires_at_idx ON mfa_challenges (expires_at);
+
+-- +goose Down
+DROP TABLE mfa_challenges;
+DROP TABLE recovery_codes;
+DROP TABLE user_totp;
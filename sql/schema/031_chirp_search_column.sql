@@ -0,0 +1,44 @@
+-- +goose Up
+-- The search document is a generated column on chirps, so it can not drift
+-- from the body and needs no trigger. Chirp queries name their columns
+-- instead of using *, so regular reads still do not carry it around.
+ALTER TABLE chirps
+ADD COLUMN search_document tsvector GENERATED ALWAYS AS (to_tsvector('english', body)) STORED;
+
+CREATE INDEX chirps_search_document_idx ON chirps USING GIN (search_document);
+
+DROP TRIGGER chirp_search_sync ON chirps;
+DROP FUNCTION chirp_search_sync();
+DROP TABLE chirp_search;
+
+-- +goose Down
+CREATE TABLE chirp_search (
+    chirp_id UUID PRIMARY KEY,
+    document tsvector NOT NULL,
+    CONSTRAINT chirp_search_chirp_foreign FOREIGN KEY (chirp_id) REFERENCES chirps (id) ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_search_document_idx ON chirp_search USING GIN (document);
+
+-- +goose StatementBegin
+CREATE FUNCTION chirp_search_sync() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO chirp_search (chirp_id, document)
+    VALUES (NEW.id, to_tsvector('english', NEW.body))
+    ON CONFLICT (chirp_id) DO UPDATE SET document = EXCLUDED.document;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirp_search_sync
+AFTER INSERT OR UPDATE OF body ON chirps
+FOR EACH ROW EXECUTE FUNCTION chirp_search_sync();
+
+INSERT INTO chirp_search (chirp_id, document)
+SELECT c.id, c.search_document FROM chirps c;
+
+DROP INDEX chirps_search_document_idx;
+
+ALTER TABLE chirps
+DROP COLUMN search_document;
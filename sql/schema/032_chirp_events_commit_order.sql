@@ -0,0 +1,15 @@
+-- +goose Up
+-- Ids are handed out when an event is inserted, not when it commits, so a
+-- reader going by id alone can pass an event that commits late. Events are
+-- read in (tx_id, id) order instead, and only once every transaction that
+-- could still add an earlier one has finished.
+ALTER TABLE chirp_events
+ADD COLUMN tx_id BIGINT NOT NULL DEFAULT pg_current_xact_id()::text::bigint;
+
+CREATE INDEX chirp_events_tx_id_id_idx ON chirp_events (tx_id, id);
+
+-- +goose Down
+DROP INDEX chirp_events_tx_id_id_idx;
+
+ALTER TABLE chirp_events
+DROP COLUMN tx_id;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Delete events can not look the thread up, the chirp is gone by the time
+-- they are read.
+ALTER TABLE chirp_events
+ADD COLUMN root_id UUID;
+
+-- +goose Down
+ALTER TABLE chirp_events
+DROP COLUMN root_id;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- A draft that fails to publish for a passing reason is retried later, so
+-- it does not hold up the drafts due after it.
+ALTER TABLE drafts
+ADD COLUMN attempts INT NOT NULL DEFAULT 0,
+ADD COLUMN next_attempt_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE drafts
+DROP COLUMN attempts,
+DROP COLUMN next_attempt_at;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Access tokens name their session, so every instance has to hear about
+-- ended sessions like it does about bumped token versions.
+-- +goose StatementBegin
+CREATE FUNCTION sessions_notify() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('token_versions', OLD.user_id::text);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER sessions_notify
+AFTER DELETE ON sessions
+FOR EACH ROW EXECUTE FUNCTION sessions_notify();
+
+-- +goose Down
+DROP TRIGGER sessions_notify ON sessions;
+DROP FUNCTION sessions_notify;